@@ -11,15 +11,86 @@ authorization {
 
 #参考transport
 transport{
-  sign_type = "md5"
+  #签名算法 可选 md5 hmac-sha256 hmac-sha512，或通过transport.RegisterSigner注册的自定义算法
+  sign_type = "hmac-sha256"
+  #服务端接受的签名算法列表，客户端迁移期间可同时开启多个，未配置时只接受sign_type
+  sign_types = ["hmac-sha256", "md5"]
+  #签名串版本 v1(默认，key+value拼接，跳过空值) v2(长度前缀编码，包含请求路径、X-Client-ID和data摘要)
+  sign_version = "v1"
+  #按客户端指定签名串版本，旧版本应用可继续使用v1
+  #sign_versions {
+  #  user1 = "v2"
+  #}
   #默认的签名验证策略，可选值有 allow deny, allow时忽略验签和签名
   default_policy = "deny"
+  #请求时间戳(毫秒)允许的时钟偏差(秒)，大于0时开启防重放，请求需携带参与签名的nonce
+  timestamp_skew = 300
+  #nonce存储 memory(单节点) redis(集群，使用redis配置)
+  nonce_store = "memory"
+  #请求体为JSON数组时按批量请求处理，batch_size为最多请求数，batch_concurrency为并发数
+  #header X-Batch-Abort=true 时任一请求失败后不再执行尚未开始的请求
+  batch_size = 20
+  batch_concurrency = 4
+  #multipart/form-data上传请求的最大字节数(信封和全部文件)，默认32MB
+  #请求信封放在envelope字段，files为 字段名=>文件SHA256 参与签名
+  upload_max_size = 33554432
+  #按业务状态码返回HTTP状态码，默认 4001=>401 1008=>403 1004~1005=>404 1001、1011=>500，其他返回200
+  #为true时所有响应返回200，兼容按业务状态码处理错误的旧客户端
+  status_always_ok = false
+  #自定义映射，优先于默认映射，to为0时只匹配from
+  #status_mapping = [
+  #  { from = 1009, to = 1010, status = 400 },
+  #  { from = 3001, status = 503 }
+  #]
+  #幂等请求，操作设置framework.EndpointOperation.Idempotent后，
+  #相同的 X-Client-ID + 账户ID + header X-Idempotency-Key 复用首次请求的签名响应
+  #idempotency {
+  #  #存储 memory(单节点) redis(集群，使用redis配置)
+  #  store = "redis"
+  #  #响应保存时长(秒)
+  #  ttl = 86400
+  #  #执行中标记的有效期(秒)
+  #  lock_ttl = 300
+  #  #重复请求等待首个请求完成的最长时间(秒)
+  #  wait = 10
+  #}
   sign_keys {
     #global 为返回数据包签名
     global = "521004524ef99ad954ad93c3f91c82fd"
     #user1对应客户端请求header里的X-Client-ID的值，然后取到密钥用来对客户端数据验签
     user1  = "d41d8cd98f00b204e9800998ecf8427e"
   }
+  #多版本轮换密钥，客户端通过header X-Sign-Key-Version指定版本，未指定时使用最晚生效的版本
+  #rotating_keys = [
+  #  {
+  #    client_id = "user1"
+  #    version = "v2"
+  #    secret = "c4ca4238a0b923820dcc509a6f75849b"
+  #    not_before = "2026-01-01T00:00:00Z"
+  #    expires_at = "2027-01-01T00:00:00Z"
+  #  }
+  #]
+  #响应签名密钥 global(使用sign_keys.global) client(使用客户端自己的密钥)
+  response_sign_key = "global"
+  #非对称签名(rsa-sha256 ed25519)的客户端公钥，key为X-Client-ID，值为base64(PKIX/PKCS1 DER)或PEM
+  #public_keys {
+  #  app1 = "MCowBQYDK2VwAyEA..."
+  #}
+  #端到端数据加密，请求data和响应content使用每次请求的AES-GCM密钥加密，参考transport/encryption.go
+  #encryption {
+  #  #为true时拒绝未加密的请求
+  #  required = false
+  #  #服务端RSA私钥，解包encrypt=rsa-aes-gcm请求的数据密钥，响应密钥使用public_keys中客户端的RSA公钥包装
+  #  private_key = "MIIEvQIBADANBgkqhkiG9w0BAQEFAASC..."
+  #  #encrypt=psk-aes-gcm时使用的客户端预共享密钥 base64(32字节)
+  #  pre_shared_keys {
+  #    user1 = "q83vEjRWeJq83vEjRWeJq83vEjRWeJq83vEjRWeJq80="
+  #  }
+  #}
+  #非对称签名的服务端私钥，key为sign_type，值为base64(PKCS8 DER)或PEM，客户端使用对应公钥验证响应签名
+  #private_keys {
+  #  ed25519 = "MC4CAQAwBQYDK2VwBCIEI..."
+  #}
 }
 
 xorm {
@@ -0,0 +1,181 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/36625090/involution/client"
+	"github.com/google/uuid"
+	"math/rand"
+	"strconv"
+	"sync"
+	"text/template"
+	"time"
+)
+
+//Settings 压测参数
+type Settings struct {
+	//Method 接口方法 backend.endpoint.operation
+	Method string
+	//Data 请求数据模板(text/template)，渲染结果必须为JSON
+	//可用变量 {{.Seq}} 请求序号 {{.Worker}} 并发序号
+	//可用函数 {{uuid}} {{randInt 1 100}} {{now}}(毫秒时间戳)
+	Data string
+	//Concurrency 并发数，默认1
+	Concurrency int
+	//RPS 目标每秒请求数，为0时不限速
+	RPS float64
+	//Requests 请求总数，为0时按Duration结束
+	Requests int
+	//Duration 压测时长，为0时按Requests结束
+	Duration time.Duration
+}
+
+//templateData 数据模板变量
+type templateData struct {
+	Seq    int
+	Worker int
+}
+
+var templateFuncs = template.FuncMap{
+	"uuid": uuid.NewString,
+	"randInt": func(min, max int) int {
+		if max <= min {
+			return min
+		}
+		return min + rand.Intn(max-min+1)
+	},
+	"now": func() int64 {
+		return time.Now().UnixMilli()
+	},
+}
+
+//sample 单个请求的结果
+type sample struct {
+	latency time.Duration
+	code    string
+}
+
+//Run 按参数发送签名请求，结束后返回统计报告
+func Run(ctx context.Context, cli *client.Client, settings *Settings) (*Report, error) {
+	if settings.Method == "" {
+		return nil, errors.New("bench requires method")
+	}
+	if settings.Requests <= 0 && settings.Duration <= 0 {
+		return nil, errors.New("bench requires requests or duration")
+	}
+	data := settings.Data
+	if data == "" {
+		data = "{}"
+	}
+	tpl, err := template.New("data").Funcs(templateFuncs).Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse data template: %v", err)
+	}
+	if _, err := render(tpl, templateData{}); err != nil {
+		return nil, err
+	}
+	concurrency := settings.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	settings = &Settings{Method: settings.Method, Data: data, Concurrency: concurrency,
+		RPS: settings.RPS, Requests: settings.Requests, Duration: settings.Duration}
+
+	if settings.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, settings.Duration)
+		defer cancel()
+	}
+
+	tickets := make(chan int, concurrency)
+	go schedule(ctx, tickets, settings.Requests, settings.RPS)
+
+	samples := make([][]sample, concurrency)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	since := time.Now()
+	for i := 0; i < concurrency; i++ {
+		go func(worker int) {
+			defer wg.Done()
+			for seq := range tickets {
+				body, err := render(tpl, templateData{Seq: seq, Worker: worker})
+				if err != nil {
+					samples[worker] = append(samples[worker], sample{code: CodeTemplate})
+					continue
+				}
+				start := time.Now()
+				_, err = cli.Do(ctx, &client.Call{Method: settings.Method, Data: json.RawMessage(body)})
+				//压测时长结束时中断的请求不计入
+				if err != nil && ctx.Err() != nil {
+					return
+				}
+				samples[worker] = append(samples[worker], sample{latency: time.Since(start), code: resultCode(err)})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var all []sample
+	for _, s := range samples {
+		all = append(all, s...)
+	}
+	return newReport(settings, all, time.Since(since)), nil
+}
+
+//schedule 按目标RPS发放请求序号，达到请求总数或ctx结束时关闭
+func schedule(ctx context.Context, tickets chan<- int, requests int, rps float64) {
+	defer close(tickets)
+	var tick <-chan time.Time
+	if rps > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / rps))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for seq := 0; requests <= 0 || seq < requests; seq++ {
+		if tick != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case tickets <- seq:
+		}
+	}
+}
+
+func render(tpl *template.Template, data templateData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("render data template: %v", err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("data template renders invalid JSON: %s", buf.String())
+	}
+	return buf.Bytes(), nil
+}
+
+//resultCode 请求结果分类，业务响应使用Response.Code
+func resultCode(err error) string {
+	if err == nil {
+		return "0"
+	}
+	var e *client.Error
+	if errors.As(err, &e) {
+		return strconv.Itoa(e.Code.Int())
+	}
+	var se *client.StatusError
+	if errors.As(err, &se) {
+		return CodeHTTPPrefix + strconv.Itoa(se.StatusCode)
+	}
+	if errors.Is(err, client.ErrInvalidSignature) {
+		return CodeSignature
+	}
+	return CodeNetwork
+}
@@ -0,0 +1,101 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/36625090/involution/client"
+	"github.com/36625090/involution/logical"
+	"github.com/36625090/involution/logical/codes"
+	"github.com/36625090/involution/transport"
+	"github.com/gin-gonic/gin"
+	"github.com/hashicorp/go-hclog"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestClient(t *testing.T) *client.Client {
+	gin.SetMode(gin.ReleaseMode)
+	tr, err := transport.NewTransport(gin.New(), &transport.Settings{
+		SignKeys: map[string]string{"c1": "secret", transport.GlobalSignKey: "secret"},
+	}, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.AddHandle("/api", logical.HttpMethodPOST, func(c *transport.Context) error {
+		payload, _ := c.Request().Data.Payload()
+		var data struct {
+			Seq int `json:"seq"`
+		}
+		_ = json.Unmarshal(payload, &data)
+		if data.Seq%4 == 0 {
+			c.WithCode(codes.CodeFailure).WithMessage("failed")
+			return errors.New("failed")
+		}
+		c.WithContent(data.Seq)
+		return nil
+	})
+	srv := httptest.NewServer(tr)
+	t.Cleanup(srv.Close)
+
+	cli, err := client.New(&client.Config{Endpoint: srv.URL + "/api", ClientID: "c1", Secret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cli
+}
+
+func TestRun(t *testing.T) {
+	cli := newTestClient(t)
+	report, err := Run(context.Background(), cli, &Settings{
+		Method:      "account.user.home",
+		Data:        `{"seq":{{.Seq}},"id":"{{uuid}}","n":{{randInt 1 10}}}`,
+		Concurrency: 4,
+		Requests:    40,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests != 40 || report.Succeeded != 30 || report.Failed != 10 ||
+		report.Codes["0"] != 30 || report.Codes["1"] != 10 {
+		t.Fatal("unexpected report", report.Requests, report.Succeeded, report.Codes)
+	}
+	var count int
+	for _, b := range report.Histogram {
+		count += b.Count
+	}
+	if count != 40 || report.Throughput <= 0 || report.Latency.P99 < report.Latency.P50 {
+		t.Fatal("unexpected latency", count, report.Throughput, report.Latency)
+	}
+
+	if _, err := Run(context.Background(), cli, &Settings{Method: "account.user.home", Data: `{"seq":`, Requests: 1}); err == nil {
+		t.Fatal("invalid template accepted")
+	}
+}
+
+func TestRun_RPS(t *testing.T) {
+	cli := newTestClient(t)
+	since := time.Now()
+	report, err := Run(context.Background(), cli, &Settings{
+		Method:      "account.user.home",
+		Data:        `{"seq":1}`,
+		Concurrency: 4,
+		RPS:         100,
+		Requests:    10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests != 10 || time.Since(since) < 90*time.Millisecond {
+		t.Fatal("rps not limited", report.Requests, time.Since(since))
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	latency := summarize([]float64{5, 1, 4, 2, 3, 10, 9, 8, 7, 6})
+	if latency.Min != 1 || latency.Max != 10 || latency.P50 != 5 || latency.P90 != 9 ||
+		latency.P99 != 10 || latency.Mean != 5.5 {
+		t.Fatal("unexpected latency", latency)
+	}
+}
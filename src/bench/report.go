@@ -0,0 +1,163 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+//请求结果分类，业务响应的分类为Response.Code
+const (
+	//CodeNetwork 网络错误或超时
+	CodeNetwork = "network"
+	//CodeSignature 响应签名校验失败
+	CodeSignature = "signature"
+	//CodeTemplate 数据模板渲染失败
+	CodeTemplate = "template"
+	//CodeHTTPPrefix 响应不是信封时按HTTP状态码分类，如 http_502
+	CodeHTTPPrefix = "http_"
+)
+
+//histogramBounds 延迟直方图的桶上限(毫秒)，最后一个桶不设上限
+var histogramBounds = []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000}
+
+//Report 压测报告，延迟单位为毫秒
+type Report struct {
+	Method      string  `json:"method"`
+	Concurrency int     `json:"concurrency"`
+	TargetRPS   float64 `json:"target_rps"`
+	Requests    int     `json:"requests"`
+	Succeeded   int     `json:"succeeded"`
+	Failed      int     `json:"failed"`
+	DurationMs  float64 `json:"duration_ms"`
+	//Throughput 实际每秒完成的请求数
+	Throughput float64        `json:"throughput"`
+	Latency    Latency        `json:"latency"`
+	Histogram  []*Bucket      `json:"histogram"`
+	Codes      map[string]int `json:"codes"`
+}
+
+//Latency 延迟统计
+type Latency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+//Bucket 直方图的桶，LE为桶上限，最后一个桶为0表示不设上限
+type Bucket struct {
+	LE    float64 `json:"le"`
+	Count int     `json:"count"`
+}
+
+func newReport(settings *Settings, samples []sample, elapsed time.Duration) *Report {
+	report := &Report{
+		Method:      settings.Method,
+		Concurrency: settings.Concurrency,
+		TargetRPS:   settings.RPS,
+		Requests:    len(samples),
+		DurationMs:  milliseconds(elapsed),
+		Codes:       make(map[string]int),
+	}
+	if elapsed > 0 {
+		report.Throughput = float64(len(samples)) / elapsed.Seconds()
+	}
+
+	for _, bound := range histogramBounds {
+		report.Histogram = append(report.Histogram, &Bucket{LE: bound})
+	}
+	report.Histogram = append(report.Histogram, &Bucket{})
+
+	var latencies []float64
+	for _, s := range samples {
+		report.Codes[s.code]++
+		if s.code == "0" {
+			report.Succeeded++
+		} else {
+			report.Failed++
+		}
+		//模板错误未发送请求，不计入延迟
+		if s.code == CodeTemplate {
+			continue
+		}
+		ms := milliseconds(s.latency)
+		latencies = append(latencies, ms)
+		idx := sort.SearchFloat64s(histogramBounds, ms)
+		report.Histogram[idx].Count++
+	}
+	report.Latency = summarize(latencies)
+	return report
+}
+
+func summarize(latencies []float64) Latency {
+	if len(latencies) == 0 {
+		return Latency{}
+	}
+	sort.Float64s(latencies)
+	var sum float64
+	for _, v := range latencies {
+		sum += v
+	}
+	return Latency{
+		Min:  latencies[0],
+		Mean: sum / float64(len(latencies)),
+		P50:  percentile(latencies, 50),
+		P90:  percentile(latencies, 90),
+		P95:  percentile(latencies, 95),
+		P99:  percentile(latencies, 99),
+		Max:  latencies[len(latencies)-1],
+	}
+}
+
+//percentile 最近秩法计算百分位，sorted必须已排序
+func percentile(sorted []float64, p float64) float64 {
+	idx := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+//Print 输出文本格式的报告
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "method:      %s\n", r.Method)
+	fmt.Fprintf(w, "requests:    %d (succeeded %d, failed %d)\n", r.Requests, r.Succeeded, r.Failed)
+	fmt.Fprintf(w, "duration:    %.2fms\n", r.DurationMs)
+	fmt.Fprintf(w, "throughput:  %.2f req/s\n", r.Throughput)
+	fmt.Fprintf(w, "latency(ms): min %.2f mean %.2f p50 %.2f p90 %.2f p95 %.2f p99 %.2f max %.2f\n",
+		r.Latency.Min, r.Latency.Mean, r.Latency.P50, r.Latency.P90, r.Latency.P95, r.Latency.P99, r.Latency.Max)
+
+	fmt.Fprintln(w, "histogram:")
+	total := r.Requests - r.Codes[CodeTemplate]
+	for _, b := range r.Histogram {
+		le := "+Inf"
+		if b.LE > 0 {
+			le = fmt.Sprintf("%g", b.LE)
+		}
+		var ratio float64
+		if total > 0 {
+			ratio = float64(b.Count) / float64(total)
+		}
+		fmt.Fprintf(w, "  <= %-6s %8d %6.2f%%\n", le, b.Count, ratio*100)
+	}
+
+	fmt.Fprintln(w, "codes:")
+	codes := make([]string, 0, len(r.Codes))
+	for code := range r.Codes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "  %-10s %8d\n", code, r.Codes[code])
+	}
+}
@@ -0,0 +1,376 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/36625090/involution/logical"
+	"github.com/36625090/involution/logical/codes"
+	"github.com/36625090/involution/transport"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	defaultVersion      = "1.0"
+	defaultTimeout      = 10 * time.Second
+	defaultRetryBackoff = 100 * time.Millisecond
+)
+
+//Config 客户端配置，签名相关配置需与服务端transport一致
+type Config struct {
+	//Endpoint 网关接口地址，如 http://127.0.0.1:8080/example/api
+	Endpoint string
+	//ClientID 请求头X-Client-ID
+	ClientID string
+	//SignType 签名算法，默认md5
+	SignType string
+	//SignVersion 签名串版本 v1(默认) v2
+	SignVersion string
+	//Secret 对称签名密钥
+	Secret string
+	//KeyVersion 密钥版本，请求头X-Sign-Key-Version
+	KeyVersion string
+	//ResponseSecret 响应验签密钥，服务端response_sign_key为global时为服务端全局密钥，为空时使用Secret
+	ResponseSecret string
+	//PrivateKey 非对称签名的客户端私钥，PEM或base64(PKCS8 DER)
+	PrivateKey string
+	//ServerPublicKey 非对称签名的服务端公钥，用于响应验签
+	ServerPublicKey string
+	//SkipVerify 不校验响应签名，服务端default_policy为allow时使用
+	SkipVerify bool
+	//Version 请求信封的接口版本，默认1.0
+	Version string
+	//Timeout 单次HTTP请求超时时间，默认10秒
+	Timeout time.Duration
+	//MaxRetries 可安全重试的调用在网络错误或5xx时的最大重试次数，参考Call.Safe
+	MaxRetries int
+	//RetryBackoff 首次重试的等待时间，之后每次翻倍，默认100毫秒
+	RetryBackoff time.Duration
+	//TokenRefresher 服务端返回CodeUnauthorized时获取新的令牌并重试一次
+	TokenRefresher func(ctx context.Context) (string, error)
+	//HTTPClient 自定义HTTP客户端，设置后忽略Timeout
+	HTTPClient *http.Client
+}
+
+//Client 网关客户端，负责请求信封签名、响应验签、令牌管理和安全重试，可并发使用
+//仅支持JSON编码，不支持端到端加密和文件上传下载
+type Client struct {
+	config     *Config
+	signer     transport.Signer
+	httpClient *http.Client
+	path       string
+
+	mu    sync.RWMutex
+	token string
+}
+
+//Call 单次调用参数
+type Call struct {
+	//Method 接口方法 backend.endpoint.operation
+	Method string
+	//Data 业务数据，编码为原生JSON
+	Data interface{}
+	//Content 响应content的解码目标，为空时不解码
+	Content interface{}
+	//Safe 调用无副作用，可按MaxRetries重试
+	Safe bool
+	//IdempotencyKey 请求头X-Idempotency-Key，设置后调用可安全重试
+	IdempotencyKey string
+	//TraceID 请求头X-Trace-ID
+	TraceID string
+	//Header 附加的请求头
+	Header http.Header
+}
+
+//Result 调用结果
+type Result struct {
+	TraceID    string
+	Pagination *logical.Pagination
+	//Response 响应信封，content和pagination为原始JSON
+	Response *transport.Response
+}
+
+//New 创建客户端
+func New(config *Config) (*Client, error) {
+	if config.Endpoint == "" || config.ClientID == "" {
+		return nil, errors.New("client requires endpoint and client id")
+	}
+	u, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := *config
+	if cfg.SignType == "" {
+		cfg.SignType = transport.SignTypeMD5
+	}
+	if cfg.SignVersion == "" {
+		cfg.SignVersion = transport.SignVersion1
+	}
+	if cfg.Version == "" {
+		cfg.Version = defaultVersion
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultRetryBackoff
+	}
+	switch cfg.SignVersion {
+	case transport.SignVersion1, transport.SignVersion2:
+	default:
+		return nil, fmt.Errorf("unsupported sign version %q", cfg.SignVersion)
+	}
+
+	signer, err := newSigner(&cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		httpClient = &http.Client{Timeout: timeout}
+	}
+
+	return &Client{config: &cfg, signer: signer, httpClient: httpClient, path: u.Path}, nil
+}
+
+//newSigner 使用transport的签名器，客户端密钥注册为ClientID，响应验签密钥注册为GlobalSignKey
+func newSigner(cfg *Config) (transport.Signer, error) {
+	responseSecret := cfg.ResponseSecret
+	if responseSecret == "" {
+		responseSecret = cfg.Secret
+	}
+	settings := &transport.Settings{
+		SignType: cfg.SignType,
+		SignKeys: map[string]string{
+			cfg.ClientID:            cfg.Secret,
+			transport.GlobalSignKey: responseSecret,
+		},
+	}
+	if cfg.KeyVersion != "" {
+		settings.RotatingKeys = []*transport.SignKey{
+			{ClientID: cfg.ClientID, Version: cfg.KeyVersion, Secret: cfg.Secret},
+		}
+	}
+	if cfg.PrivateKey != "" {
+		settings.PrivateKeys = map[string]string{cfg.SignType: cfg.PrivateKey}
+	}
+	if cfg.ServerPublicKey != "" {
+		settings.PublicKeys = map[string]string{transport.GlobalSignKey: cfg.ServerPublicKey}
+	}
+
+	signers, err := transport.NewSigners(settings)
+	if err != nil {
+		return nil, err
+	}
+	return signers[cfg.SignType], nil
+}
+
+//SetToken 设置请求头Authorization使用的令牌，为空时不发送
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+}
+
+//Token 当前使用的令牌
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+//Call 调用接口，响应content解码到content，业务错误返回*Error
+func (c *Client) Call(ctx context.Context, method string, data interface{}, content interface{}) (*Result, error) {
+	return c.Do(ctx, &Call{Method: method, Data: data, Content: content})
+}
+
+//Do 执行调用
+//可安全重试的调用在网络错误或HTTP 5xx时按指数退避重试，每次重试使用新的nonce和时间戳重新签名
+func (c *Client) Do(ctx context.Context, call *Call) (*Result, error) {
+	data, err := transport.NewData(call.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := 1
+	if call.Safe || call.IdempotencyKey != "" {
+		attempts += c.config.MaxRetries
+	}
+
+	refreshed := false
+	backoff := c.config.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		result, retryable, err := c.do(ctx, call, data)
+		if err == nil {
+			return result, nil
+		}
+
+		//令牌失效时请求未被处理，刷新令牌后重试一次
+		if IsCode(err, codes.CodeUnauthorized) && c.config.TokenRefresher != nil && !refreshed {
+			token, rerr := c.config.TokenRefresher(ctx)
+			if rerr != nil {
+				return nil, fmt.Errorf("refresh token: %v", rerr)
+			}
+			c.SetToken(token)
+			refreshed = true
+			attempt--
+			continue
+		}
+
+		if !retryable || attempt >= attempts {
+			return result, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+//do 发送一次请求，返回的bool表示失败是否可以重试
+func (c *Client) do(ctx context.Context, call *Call, data transport.Data) (*Result, bool, error) {
+	body, err := c.envelope(call.Method, data)
+	if err != nil {
+		return nil, false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+	for key, values := range call.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", transport.MIMEJSON)
+	req.Header.Set("Accept", transport.MIMEJSON)
+	req.Header.Set(logical.HeaderClientIDKey.String(), c.config.ClientID)
+	if c.config.KeyVersion != "" {
+		req.Header.Set(logical.HeaderSignKeyVersionKey.String(), c.config.KeyVersion)
+	}
+	if token := c.Token(); token != "" {
+		req.Header.Set(logical.HeaderAuthorizationKey.String(), token)
+	}
+	if call.TraceID != "" {
+		req.Header.Set(logical.HeaderTraceIDKey.String(), call.TraceID)
+	}
+	if call.IdempotencyKey != "" {
+		req.Header.Set(logical.HeaderIdempotencyKey.String(), call.IdempotencyKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	bs, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, err
+	}
+
+	retryable := resp.StatusCode >= http.StatusInternalServerError
+	var env envelope
+	if err := json.Unmarshal(bs, &env); err != nil {
+		return nil, retryable, &StatusError{StatusCode: resp.StatusCode, Body: string(bs)}
+	}
+	response := env.response()
+	result := &Result{TraceID: response.TraceID, Response: response}
+
+	//成功响应总是验签，错误响应签名时验签后才使用code和message
+	success := response.Code == codes.CodeSuccess.Int()
+	if success || response.Sign != "" {
+		if err := c.verify(response); err != nil {
+			return result, false, err
+		}
+	}
+	if !success {
+		return result, retryable, &Error{
+			Code:       codes.ReturnCode(response.Code),
+			Message:    response.Message,
+			TraceID:    response.TraceID,
+			StatusCode: resp.StatusCode,
+		}
+	}
+
+	if len(env.Pagination) > 0 && string(env.Pagination) != "null" {
+		result.Pagination = &logical.Pagination{}
+		if err := json.Unmarshal(env.Pagination, result.Pagination); err != nil {
+			return result, false, fmt.Errorf("decode pagination: %v", err)
+		}
+	}
+	if call.Content != nil && len(env.Content) > 0 {
+		if err := json.Unmarshal(env.Content, call.Content); err != nil {
+			return result, false, fmt.Errorf("decode content: %v", err)
+		}
+	}
+	return result, false, nil
+}
+
+//envelope 生成签名后的请求信封
+func (c *Client) envelope(method string, data transport.Data) ([]byte, error) {
+	req := &transport.Request{
+		Method:    method,
+		Data:      data,
+		Timestamp: time.Now().UnixMilli(),
+		Nonce:     uuid.NewString(),
+		Version:   c.config.Version,
+		SignType:  c.config.SignType,
+	}
+	kid := transport.KeyID{ID: c.config.ClientID, Version: c.config.KeyVersion}
+	sign, err := c.signer.Sign(kid, c.canonical(req))
+	if err != nil {
+		return nil, fmt.Errorf("sign request: %v", err)
+	}
+	req.Sign = sign
+	return json.Marshal(req)
+}
+
+//verify 校验成功响应的签名
+func (c *Client) verify(resp *transport.Response) error {
+	if c.config.SkipVerify {
+		return nil
+	}
+	kid := transport.KeyID{ID: transport.GlobalSignKey}
+	if err := c.signer.Verify(kid, resp.Sign, c.canonical(resp)); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return nil
+}
+
+func (c *Client) canonical(codec transport.Codec) transport.Codec {
+	if c.config.SignVersion != transport.SignVersion2 {
+		return codec
+	}
+	return transport.CanonicalV2(codec, c.path, c.config.ClientID)
+}
+
+//envelope 响应信封，content和pagination保留原始JSON用于验签
+type envelope struct {
+	transport.Response
+	Content    json.RawMessage `json:"content"`
+	Pagination json.RawMessage `json:"pagination"`
+}
+
+func (e *envelope) response() *transport.Response {
+	resp := e.Response
+	resp.Content, resp.Pagination = rawValue(e.Content), rawValue(e.Pagination)
+	return &resp
+}
+
+func rawValue(raw json.RawMessage) interface{} {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	return raw
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/36625090/involution/logical"
+	"github.com/36625090/involution/logical/codes"
+	"github.com/36625090/involution/transport"
+	"github.com/gin-gonic/gin"
+	"github.com/hashicorp/go-hclog"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+type user struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func newTestServer(t *testing.T, settings *transport.Settings) (*httptest.Server, *int32) {
+	gin.SetMode(gin.ReleaseMode)
+	tr, err := transport.NewTransport(gin.New(), settings, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls int32
+	tr.AddHandle("/example/api", logical.HttpMethodPOST, func(c *transport.Context) error {
+		n := atomic.AddInt32(&calls, 1)
+		switch c.Request().Operation() {
+		case "flaky":
+			if n == 1 {
+				c.WithCode(codes.CodeServerInternalError).WithMessage("try again")
+				return errors.New("try again")
+			}
+		case "reject":
+			//业务错误的响应签名
+			c.WithCode(codes.CodeFailure).WithMessage("rejected")
+			return nil
+		case "private":
+			if c.GetAuthToken() != "token-2" {
+				c.WithCode(codes.CodeUnauthorized).WithMessage("unauthorized")
+				return errors.New("unauthorized")
+			}
+		}
+		payload, _ := c.Request().Data.Payload()
+		var u user
+		_ = json.Unmarshal(payload, &u)
+		u.Age++
+		c.WithContent(&u).WithPagination(&logical.Pagination{Page: 1, Size: 10, Total: 11})
+		return nil
+	})
+	srv := httptest.NewServer(tr)
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestClient_Call(t *testing.T) {
+	for _, version := range []string{transport.SignVersion1, transport.SignVersion2} {
+		srv, _ := newTestServer(t, &transport.Settings{
+			SignType:           transport.SignTypeHmacSHA256,
+			SignKeys:           map[string]string{"c1": "client-secret", transport.GlobalSignKey: "server-secret"},
+			DefaultSignVersion: version,
+			TimestampSkew:      60,
+		})
+		cli, err := New(&Config{
+			Endpoint:       srv.URL + "/example/api",
+			ClientID:       "c1",
+			SignType:       transport.SignTypeHmacSHA256,
+			SignVersion:    version,
+			Secret:         "client-secret",
+			ResponseSecret: "server-secret",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var out user
+		result, err := cli.Call(context.Background(), "account.user.home", &user{Name: "<a&b>", Age: 1}, &out)
+		if err != nil {
+			t.Fatal(version, err)
+		}
+		if out.Name != "<a&b>" || out.Age != 2 || result.Pagination == nil || result.Pagination.Total != 11 {
+			t.Fatal(version, "unexpected result", out, result.Pagination)
+		}
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	srv, calls := newTestServer(t, &transport.Settings{
+		SignKeys: map[string]string{"c1": "client-secret", transport.GlobalSignKey: "server-secret"},
+	})
+	config := &Config{
+		Endpoint:       srv.URL + "/example/api",
+		ClientID:       "c1",
+		Secret:         "client-secret",
+		ResponseSecret: "server-secret",
+		MaxRetries:     2,
+		RetryBackoff:   1,
+	}
+	cli, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	//非安全调用不重试
+	_, err = cli.Call(ctx, "account.user.flaky", &user{}, nil)
+	if !IsCode(err, codes.CodeServerInternalError) || atomic.LoadInt32(calls) != 1 {
+		t.Fatal("unexpected error", err, *calls)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.StatusCode != 500 || e.Message != "try again" {
+		t.Fatal("unexpected typed error", e)
+	}
+
+	atomic.StoreInt32(calls, 0)
+	if _, err = cli.Do(ctx, &Call{Method: "account.user.flaky", Data: &user{}, Safe: true}); err != nil {
+		t.Fatal("safe call not retried", err)
+	}
+	if atomic.LoadInt32(calls) != 2 {
+		t.Fatal("unexpected calls", *calls)
+	}
+
+	//签名的业务错误验签后返回
+	if _, err = cli.Call(ctx, "account.user.reject", &user{}, nil); !IsCode(err, codes.CodeFailure) {
+		t.Fatal("unexpected business error", err)
+	}
+
+	//令牌失效时刷新后重试
+	config.TokenRefresher = func(ctx context.Context) (string, error) {
+		return "token-2", nil
+	}
+	cli, _ = New(config)
+	cli.SetToken("token-1")
+	if _, err = cli.Call(ctx, "account.user.private", &user{}, nil); err != nil {
+		t.Fatal("token not refreshed", err)
+	}
+	if cli.Token() != "token-2" {
+		t.Fatal("unexpected token", cli.Token())
+	}
+
+	//响应验签失败
+	config.ResponseSecret = "wrong"
+	cli, _ = New(config)
+	if _, err = cli.Call(ctx, "account.user.home", &user{}, nil); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatal("response signature not verified", err)
+	}
+	if _, err = cli.Call(ctx, "account.user.reject", &user{}, nil); !errors.Is(err, ErrInvalidSignature) || IsCode(err, codes.CodeFailure) {
+		t.Fatal("error response signature not verified", err)
+	}
+}
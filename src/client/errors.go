@@ -0,0 +1,39 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"github.com/36625090/involution/logical/codes"
+)
+
+//ErrInvalidSignature 响应签名校验失败
+var ErrInvalidSignature = errors.New("invalid response signature")
+
+//Error 服务端返回的业务错误，对应响应信封的code和message
+type Error struct {
+	Code    codes.ReturnCode
+	Message string
+	TraceID string
+	//StatusCode HTTP状态码
+	StatusCode int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("code=%d message=%s trace_id=%s", e.Code, e.Message, e.TraceID)
+}
+
+//StatusError 服务端返回的内容不是响应信封，如网关或代理的错误页
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response status %d: %s", e.StatusCode, e.Body)
+}
+
+//IsCode 错误是否为指定业务状态码的*Error
+func IsCode(err error, code codes.ReturnCode) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Code == code
+}
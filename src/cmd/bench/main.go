@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/36625090/involution/bench"
+	"github.com/36625090/involution/client"
+	"github.com/jessevdk/go-flags"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+//defaultRequests 未设置--requests和--duration时的请求总数
+const defaultRequests = 1000
+
+//Options 压测命令参数
+type Options struct {
+	Endpoint       string        `long:"endpoint" required:"true" description:"Api endpoint, e.g. http://127.0.0.1:8080/example/api"`
+	Method         string        `long:"method" required:"true" description:"Method for request envelope, backend.endpoint.operation"`
+	Data           string        `long:"data" default:"{}" description:"Data template(text/template) rendering JSON, supports {{.Seq}} {{.Worker}} {{uuid}} {{randInt 1 100}} {{now}}"`
+	DataFile       string        `long:"data-file" description:"Read data template from file, overrides --data"`
+	ClientID       string        `long:"client-id" required:"true" description:"Client id for header X-Client-ID"`
+	Secret         string        `long:"secret" description:"Client key for symmetric signature"`
+	KeyVersion     string        `long:"key-version" description:"Key version for header X-Sign-Key-Version"`
+	ResponseSecret string        `long:"response-secret" description:"Key verifying response signature, defaults to --secret"`
+	PrivateKey     string        `long:"private-key" description:"File of client private key for asymmetric signature"`
+	ServerKey      string        `long:"server-public-key" description:"File of server public key verifying asymmetric response signature"`
+	SignType       string        `long:"sign-type" default:"md5" description:"Sign type"`
+	SignVersion    string        `long:"sign-version" default:"v1" choice:"v1" choice:"v2" description:"Sign version"`
+	SkipVerify     bool          `long:"skip-verify" description:"Skip response signature verification"`
+	Token          string        `long:"token" description:"Token for header Authorization"`
+	Version        string        `long:"version" default:"1.0" description:"Version for request envelope"`
+	Concurrency    int           `long:"concurrency" short:"c" default:"10" description:"Number of concurrent workers"`
+	RPS            float64       `long:"rps" default:"0" description:"Target requests per second, 0 means unlimited"`
+	Requests       int           `long:"requests" short:"n" description:"Total requests, defaults to 1000 without --duration, 0 means run until --duration"`
+	Duration       time.Duration `long:"duration" short:"d" description:"Duration of the run, e.g. 30s"`
+	Timeout        time.Duration `long:"timeout" default:"10s" description:"Timeout for each request"`
+	JSON           bool          `long:"json" description:"Print report as JSON"`
+	Output         string        `long:"output" short:"o" description:"Write JSON report to file"`
+}
+
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lshortfile)
+
+	var opts Options
+	if _, err := flags.Parse(&opts); err != nil {
+		os.Exit(1)
+	}
+
+	//设置--duration时默认不限制请求数，按时长结束
+	if opts.Requests == 0 && opts.Duration == 0 {
+		opts.Requests = defaultRequests
+	}
+
+	data := opts.Data
+	if opts.DataFile != "" {
+		bs, err := ioutil.ReadFile(opts.DataFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		data = string(bs)
+	}
+	privateKey, err := readKey(opts.PrivateKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	serverKey, err := readKey(opts.ServerKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cli, err := client.New(&client.Config{
+		Endpoint:        opts.Endpoint,
+		ClientID:        opts.ClientID,
+		SignType:        opts.SignType,
+		SignVersion:     opts.SignVersion,
+		Secret:          opts.Secret,
+		KeyVersion:      opts.KeyVersion,
+		ResponseSecret:  opts.ResponseSecret,
+		PrivateKey:      privateKey,
+		ServerPublicKey: serverKey,
+		SkipVerify:      opts.SkipVerify,
+		Version:         opts.Version,
+		Timeout:         opts.Timeout,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	cli.SetToken(opts.Token)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	report, err := bench.Run(ctx, cli, &bench.Settings{
+		Method:      opts.Method,
+		Data:        data,
+		Concurrency: opts.Concurrency,
+		RPS:         opts.RPS,
+		Requests:    opts.Requests,
+		Duration:    opts.Duration,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	bs, _ := json.MarshalIndent(report, "", "  ")
+	if opts.Output != "" {
+		if err := ioutil.WriteFile(opts.Output, bs, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if opts.JSON {
+		os.Stdout.Write(append(bs, '\n'))
+		return
+	}
+	report.Print(os.Stdout)
+}
+
+func readKey(path string) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	bs, err := ioutil.ReadFile(path)
+	return string(bs), err
+}
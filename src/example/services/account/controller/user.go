@@ -29,6 +29,8 @@ func (b *backend) userPaths() *framework.Endpoint {
 			home: &framework.EndpointOperation{
 				Description: "用户主页",
 				Callback:    b.userHome,
+				Method:      logical.HttpMethodGET,
+				Path:        "/account/user/home",
 				Input:       reflect.TypeOf(logical.EmptyDocuments{}),
 				Output:      reflect.TypeOf(logical.EmptyDocuments{}),
 			},
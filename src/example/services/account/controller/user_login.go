@@ -50,7 +50,7 @@ func (b *backend) userLogin(ctx context.Context, args *logical.Args, reply *logi
 	}
 
 	cb, _ := json.Marshal(authorized)
-	if err := b.RedisCli.Set(authorized.ID, cb, "1h"); err != nil {
+	if err := b.Redis(ctx).Set(authorized.ID, cb, "1h"); err != nil {
 		reply.Code = 104
 		reply.Message = err.Error()
 		return nil
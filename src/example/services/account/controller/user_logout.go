@@ -10,7 +10,7 @@ import (
 func (b *backend) userLogout(ctx context.Context, args *logical.Args, reply *logical.Reply) *logical.WrapperError {
 
 
-	cli, err := b.LBAdapter.Client("userservice","").RestyClient()
+	cli, err := b.RestyClient("userservice","")
 	if err != nil {
 		return &logical.WrapperError{
 			Code: codes.CodeServiceException,
@@ -24,7 +24,8 @@ func (b *backend) userLogout(ctx context.Context, args *logical.Args, reply *log
 	}
 
 	request :=  cli.GetRequest()
-	request.SetHeader("x-trace-id", args.GetTraceID())
+	//自动传递X-Trace-ID traceparent tracestate，开启链路追踪时以当前span作为traceparent
+	request.SetContext(ctx)
 	response, err := request.SetBody(body).
 		SetHeader("Biz-ProductId","22222").
 		Post("/users/loginByMobilePhone")
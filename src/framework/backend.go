@@ -7,6 +7,7 @@ import (
 	"github.com/36625090/involution/authorities"
 	"github.com/36625090/involution/logical"
 	"github.com/36625090/involution/logical/codes"
+	"github.com/36625090/involution/tracing"
 	"github.com/36625090/involution/utils"
 	"github.com/go-playground/validator/v10"
 	"github.com/go-various/consul"
@@ -14,6 +15,7 @@ import (
 	"github.com/go-various/redisplus"
 	"github.com/go-various/xorm"
 	log "github.com/hashicorp/go-hclog"
+	"go.opentelemetry.io/otel/trace"
 	"runtime/debug"
 	"strings"
 	"sync"
@@ -21,6 +23,7 @@ import (
 
 var ErrEndpointNotExists = errors.New("endpoint not found")
 var ErrOperationNotExists = errors.New("operation not exists")
+var ErrMicroServiceUnavailable = errors.New("micro service client unavailable, consul required")
 
 // Backend is an implementation of logical.Backend
 var _ logical.Backend = (*Backend)(nil)
@@ -39,10 +42,17 @@ type Backend struct {
 	RedisCli                redisplus.RedisCli
 	LBAdapter               micro.LBAdapter
 	TokenHandler            authorities.TokenHandler
+	Pusher                  logical.Pusher
 	Clean                   CleanupFunc
 	InitializeFunc          InitializeFunc
 	HandleRequestBeforeFunc HandleRequestBeforeFunc
-	validator               *validator.Validate
+	//CacheStore 操作响应缓存的存储 memory(默认，LRU) redis
+	CacheStore string
+	//CacheSize 内存缓存最多缓存的响应数，默认1024
+	CacheSize    int
+	cache        ResponseCache
+	revalidating sync.Map
+	validator    *validator.Validate
 }
 
 func (b *Backend) BackendName() string {
@@ -61,11 +71,19 @@ type HandleRequestBeforeFunc func(context.Context, *logical.Args)
 // OperationFunc 请求操作函数
 type OperationFunc func(context.Context, *logical.Args, *logical.Reply) *logical.WrapperError
 
+// StreamOperationFunc 流式请求操作函数，通过StreamWriter推送进度和部分结果，最终结果写入Reply
+type StreamOperationFunc func(context.Context, *logical.Args, logical.StreamWriter, *logical.Reply) *logical.WrapperError
+
 // CleanupFunc 清理函数
 type CleanupFunc func(context.Context)
 
 // HandleRequest 处理请求逻辑
 func (b *Backend) HandleRequest(ctx context.Context, req *logical.Args) (resp *logical.Reply, err *logical.WrapperError) {
+	ctx, span := tracing.Start(ctx, "Backend.HandleRequest", trace.WithAttributes(tracing.Args(req)...))
+	defer func() {
+		endSpan(span, err)
+	}()
+
 	if b.Logger.IsTrace() {
 		b.Logger.Trace("handle request before", "request", utils.JSONDump(req))
 	}
@@ -79,10 +97,11 @@ func (b *Backend) HandleRequest(ctx context.Context, req *logical.Args) (resp *l
 			b.Logger.Error("handle request",
 				"backend", b.Config.Application, "endpoint",
 				req.Endpoint, "operation", req.Operation,
+				"trace-id", req.GetTraceID(),
 				"error", err)
 		}
 		if err2 := recover(); nil != err2 {
-			b.Logger.Error("recover panic", "err", err2, "stack", string(debug.Stack()))
+			b.Logger.Error("recover panic", "trace-id", req.GetTraceID(), "err", err2, "stack", string(debug.Stack()))
 			err = &logical.WrapperError{
 				Code:  codes.CodeServerInternalError,
 				Scope: "",
@@ -106,6 +125,8 @@ func (b *Backend) HandleRequest(ctx context.Context, req *logical.Args) (resp *l
 			Err:   errors.New("trace ID is required"),
 		}
 	}
+	//RestyClient从上下文读取链路追踪请求头
+	ctx = logical.WithTraceHeaders(ctx, req.TraceHeaders())
 	// Find the matching route
 	path := b.find(req.Endpoint)
 	if nil == path {
@@ -125,6 +146,19 @@ func (b *Backend) HandleRequest(ctx context.Context, req *logical.Args) (resp *l
 		}
 	}
 
+	version := ""
+	if versioned, ok := operation.(*VersionedOperation); ok {
+		name, selected, serr := versioned.Select(req.Version)
+		if serr != nil {
+			return nil, &logical.WrapperError{
+				Code:  codes.CodeUnsupportedVersion,
+				Scope: "",
+				Err:   serr,
+			}
+		}
+		operation, version = selected, name
+	}
+
 	if operation.Handler() == nil {
 		return nil, &logical.WrapperError{
 			Code:  codes.CodeOperationHandlerIssue,
@@ -134,13 +168,74 @@ func (b *Backend) HandleRequest(ctx context.Context, req *logical.Args) (resp *l
 		}
 	}
 
+	if err := checkUploads(operation.Properties().Uploads, req.Files); err != nil {
+		return nil, &logical.WrapperError{
+			Code:  codes.CodeInvalidUpload,
+			Scope: "",
+			Err:   err,
+		}
+	}
+
 	if b.HandleRequestBeforeFunc != nil {
 		b.HandleRequestBeforeFunc(ctx, req)
 	}
-	err = operation.Handler()(ctx, req, resp)
+	handler := traceHandler(operation.Handler())
+	if cache := operation.Properties().Cache; cache != nil && b.cache != nil {
+		err = b.handleCached(ctx, handler, cache, version, req, resp)
+		return resp, err
+	}
+	err = handler(ctx, req, resp)
 	return resp, err
 }
 
+//traceHandler 操作函数的span，以backend.endpoint.operation命名，缓存命中时不执行
+func traceHandler(handler OperationFunc) OperationFunc {
+	return func(ctx context.Context, req *logical.Args, resp *logical.Reply) (err *logical.WrapperError) {
+		ctx, span := tracing.Start(ctx, strings.Join([]string{req.Backend, req.Endpoint, req.Operation}, "."),
+			trace.WithAttributes(tracing.Args(req)...))
+		defer func() {
+			endSpan(span, err)
+		}()
+		return handler(ctx, req, resp)
+	}
+}
+
+//endSpan 结束span，记录错误码
+func endSpan(span trace.Span, err *logical.WrapperError) {
+	if err == nil {
+		tracing.End(span, nil)
+		return
+	}
+	span.SetAttributes(tracing.AttrCode.Int(err.Code.Int()))
+	if err.Err != nil {
+		tracing.End(span, err.Err)
+		return
+	}
+	tracing.End(span, errors.New(err.String()))
+}
+
+//RestyClient 通过服务发现创建调用其他服务的客户端，每个请求自动传递X-Trace-ID traceparent tracestate
+//开启链路追踪时每个请求创建span，traceparent为该span；请求需通过SetContext(ctx)传入操作函数的上下文
+func (b *Backend) RestyClient(name, tags string) (*micro.RestyClient, error) {
+	if b.LBAdapter == nil {
+		return nil, ErrMicroServiceUnavailable
+	}
+	cli, err := b.LBAdapter.Client(name, tags).RestyClient()
+	if err != nil {
+		return nil, err
+	}
+	tracing.RestyHeaders(cli.GetRawClient())
+	if tracing.Enabled() {
+		tracing.Resty(cli.GetRawClient(), name)
+	}
+	return cli, nil
+}
+
+//Redis 返回携带操作函数上下文的redis客户端，开启链路追踪时每次调用创建该请求的子span
+func (b *Backend) Redis(ctx context.Context) redisplus.RedisCli {
+	return tracing.RedisCli(ctx, b.RedisCli)
+}
+
 // Cleanup 清理函数
 func (b *Backend) Cleanup(ctx context.Context) {
 	b.Logger.Trace("cleaning")
@@ -164,6 +259,9 @@ func (b *Backend) Initialize(ctx context.Context) (err error) {
 	if err != nil {
 		return err
 	}
+	if tracing.Enabled() {
+		b.XormPlus.AddHook(&tracing.XormHook{System: b.XormPlus.DriverName()})
+	}
 
 	//初始化redis
 	prefix := strings.Join([]string{b.Config.Application, b.Name}, ":")
@@ -172,6 +270,11 @@ func (b *Backend) Initialize(ctx context.Context) (err error) {
 		return err
 	}
 
+	//初始化响应缓存
+	if err = b.initCache(); err != nil {
+		return err
+	}
+
 	//初始化微服务客户端
 	if b.Config.Consul != nil {
 		b.ConsulClient = b.Config.Consul
@@ -181,6 +284,9 @@ func (b *Backend) Initialize(ctx context.Context) (err error) {
 	//初始化验证接口
 	b.TokenHandler = b.Config.TokenHandler
 
+	//初始化WebSocket推送接口
+	b.Pusher = b.Config.Pusher
+
 	if b.InitializeFunc != nil {
 		b.InitializeFunc(ctx)
 	}
@@ -190,13 +296,62 @@ func (b *Backend) Initialize(ctx context.Context) (err error) {
 
 //检查路径配置是否正确
 func (b *Backend) checkEndpoint() error {
-
+	routes := map[string]string{}
 	for _, p := range b.Endpoints {
 		for operation, handler := range p.Operations {
+			if versioned, ok := handler.(*VersionedOperation); ok {
+				if err := versioned.check(); err != nil {
+					return fmt.Errorf("operation version: %s.%s.%s %s",
+						b.Name, p.Pattern, operation, err)
+				}
+			}
 			if handler.Handler() == nil {
 				return fmt.Errorf("operation callback: %s.%s.%s cannot be nil",
 					b.Name, p.Pattern, operation)
 			}
+			if err := checkCache(handler); err != nil {
+				return fmt.Errorf("operation cache: %s.%s.%s %s",
+					b.Name, p.Pattern, operation, err)
+			}
+			properties := handler.Properties()
+			if properties.Method == "" && properties.Path == "" {
+				continue
+			}
+			if properties.Method == "" || !strings.HasPrefix(properties.Path, "/") {
+				return fmt.Errorf("operation route: %s.%s.%s requires method and absolute path",
+					b.Name, p.Pattern, operation)
+			}
+			route := string(properties.Method) + " " + properties.Path
+			if exists, ok := routes[route]; ok {
+				return fmt.Errorf("operation route: %s.%s.%s duplicates %s on %s",
+					b.Name, p.Pattern, operation, exists, route)
+			}
+			routes[route] = strings.Join([]string{b.Name, p.Pattern, operation}, ".")
+		}
+	}
+	return nil
+}
+
+//checkUploads 按操作声明校验上传文件
+func checkUploads(uploads []*UploadField, files []*logical.File) error {
+	declared := map[string]*UploadField{}
+	for _, upload := range uploads {
+		declared[upload.Field] = upload
+	}
+	received := map[string]bool{}
+	for _, file := range files {
+		upload, ok := declared[file.Field]
+		if !ok {
+			return fmt.Errorf("upload field not accepted: %s", file.Field)
+		}
+		if upload.MaxSize > 0 && file.Size > upload.MaxSize {
+			return fmt.Errorf("upload field %s exceeds %d bytes", file.Field, upload.MaxSize)
+		}
+		received[file.Field] = true
+	}
+	for _, upload := range uploads {
+		if upload.Required && !received[upload.Field] {
+			return fmt.Errorf("upload field required: %s", upload.Field)
 		}
 	}
 	return nil
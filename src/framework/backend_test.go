@@ -0,0 +1,48 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/36625090/involution/logical"
+	"github.com/go-playground/validator/v10"
+	"github.com/hashicorp/go-hclog"
+	"testing"
+)
+
+func TestBackend_TraceHeaders(t *testing.T) {
+	var headers map[string]string
+	backend := &Backend{
+		Name:   "account",
+		Logger: hclog.NewNullLogger(),
+		Config: &logical.BackendContext{},
+		Endpoints: []*Endpoint{{
+			Pattern:     "user",
+			Description: "user",
+			Operations: map[string]OperationHandler{
+				"logout": &EndpointOperation{
+					Description: "logout",
+					Callback: func(ctx context.Context, args *logical.Args, reply *logical.Reply) *logical.WrapperError {
+						headers = logical.TraceHeadersFromContext(ctx)
+						return nil
+					},
+				},
+			},
+		}},
+		validator: validator.New(),
+	}
+
+	args := &logical.Args{
+		Backend: "account", Endpoint: "user", Operation: "logout",
+		Data:       json.RawMessage(`{}`),
+		Headers:    map[string][]string{logical.HeaderTraceparentKey.String(): {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}},
+		Connection: &logical.Connection{RemoteAddr: "127.0.0.1", UserAgent: "test"},
+	}
+	args.SetTraceID("trace-1")
+	if _, werr := backend.HandleRequest(context.Background(), args); werr != nil {
+		t.Fatal(werr)
+	}
+	if headers[logical.HeaderTraceIDKey.String()] != "trace-1" ||
+		headers[logical.HeaderTraceparentKey.String()] != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Fatal("trace headers not in operation context", headers)
+	}
+}
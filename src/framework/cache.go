@@ -0,0 +1,423 @@
+package framework
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/36625090/involution/logical"
+	"github.com/go-various/redisplus"
+	lru "github.com/hashicorp/golang-lru"
+	"gopkg.in/redis.v5"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CacheStoreMemory = "memory"
+	CacheStoreRedis  = "redis"
+
+	defaultCacheSize = 1024
+)
+
+//CacheSettings 操作响应缓存，只缓存成功的响应
+//缓存key为 backend、endpoint、operation、选中的版本及规范化后的Args.Data 的SHA256，按设置附加账户ID、角色和客户端ID
+//已鉴权的请求默认按账户区分，所有账户共用的响应需设置Shared
+type CacheSettings struct {
+	//TTL 响应有效期，必须大于0
+	TTL time.Duration
+	//StaleWhileRevalidate 过期后该时长内仍返回旧的响应，并在后台刷新，不能为负
+	StaleWhileRevalidate time.Duration
+	//VaryByAccount 未鉴权的请求也按账户区分(账户为空)，已鉴权的请求不设置Shared时总是按账户区分
+	VaryByAccount bool
+	//Shared 已鉴权的请求不按账户区分，响应与账户无关时使用
+	Shared       bool
+	VaryByRole   bool
+	VaryByClient bool
+	//Tags 缓存标签，通过Backend.InvalidateCache按标签失效
+	Tags []string
+	//TagsFunc 按请求生成的标签，如 user:{id}
+	TagsFunc func(*logical.Args) []string `json:"-"`
+}
+
+//ResponseCache 操作响应缓存的存储接口
+type ResponseCache interface {
+	//Get 读取缓存，不存在时返回nil
+	Get(key string) ([]byte, error)
+	Set(key string, value []byte, ttl time.Duration, tags []string) error
+	//Invalidate 删除标签关联的全部缓存
+	Invalidate(tags ...string) error
+}
+
+//cacheEntry 缓存的响应，FreshUntil之后为过期数据
+type cacheEntry struct {
+	Data       json.RawMessage     `json:"data"`
+	Message    string              `json:"message,omitempty"`
+	Pagination *logical.Pagination `json:"pagination,omitempty"`
+	FreshUntil int64               `json:"fresh_until"`
+}
+
+//initCache 按CacheStore创建缓存，没有声明缓存的操作时不创建
+func (b *Backend) initCache() error {
+	if b.cache != nil || !b.hasCacheOperation() {
+		return nil
+	}
+	switch b.CacheStore {
+	case CacheStoreRedis:
+		b.cache = NewRedisCache(b.RedisCli)
+	case "", CacheStoreMemory:
+		cache, err := NewMemoryCache(b.CacheSize)
+		if err != nil {
+			return err
+		}
+		b.cache = cache
+	default:
+		return errors.New("unsupported cache store: " + b.CacheStore)
+	}
+	return nil
+}
+
+//checkCache 校验操作及其各版本的缓存设置
+func checkCache(handler OperationHandler) error {
+	handlers := []OperationHandler{handler}
+	if versioned, ok := handler.(*VersionedOperation); ok {
+		for _, version := range versioned.Versions {
+			handlers = append(handlers, version)
+		}
+	}
+	for _, h := range handlers {
+		if err := h.Properties().Cache.check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//check TTL必须大于0，StaleWhileRevalidate不能为负
+func (s *CacheSettings) check() error {
+	if s == nil {
+		return nil
+	}
+	if s.TTL <= 0 {
+		return errors.New("ttl must be positive")
+	}
+	if s.StaleWhileRevalidate < 0 {
+		return errors.New("stale while revalidate cannot be negative")
+	}
+	return nil
+}
+
+func (b *Backend) hasCacheOperation() bool {
+	for _, p := range b.Endpoints {
+		for _, handler := range p.Operations {
+			if versioned, ok := handler.(*VersionedOperation); ok {
+				for _, version := range versioned.Versions {
+					if version.Properties().Cache != nil {
+						return true
+					}
+				}
+			}
+			if handler.Properties().Cache != nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//InvalidateCache 按标签删除缓存的响应
+func (b *Backend) InvalidateCache(ctx context.Context, tags ...string) error {
+	if b.cache == nil || len(tags) == 0 {
+		return nil
+	}
+	return b.cache.Invalidate(tags...)
+}
+
+//handleCached 从缓存返回响应，未命中时执行操作并缓存成功的响应
+//过期但仍在StaleWhileRevalidate时长内时返回旧的响应，同一key只有一个后台刷新
+func (b *Backend) handleCached(ctx context.Context, handler OperationFunc, settings *CacheSettings,
+	version string, req *logical.Args, resp *logical.Reply) *logical.WrapperError {
+	key, err := cacheKey(version, settings, req)
+	if err != nil {
+		b.Logger.Warn("cache key", "err", err)
+		return handler(ctx, req, resp)
+	}
+
+	bs, err := b.cache.Get(key)
+	if err != nil {
+		b.Logger.Warn("cache get", "key", key, "err", err)
+	}
+	if bs != nil {
+		var entry cacheEntry
+		if err := json.Unmarshal(bs, &entry); err == nil {
+			if time.Now().UnixMilli() >= entry.FreshUntil {
+				b.revalidate(handler, settings, key, req)
+			}
+			return entry.reply(resp)
+		}
+	}
+
+	werr := handler(ctx, req, resp)
+	if werr == nil {
+		b.storeCache(settings, key, req, resp)
+	}
+	return werr
+}
+
+func (b *Backend) revalidate(handler OperationFunc, settings *CacheSettings, key string, req *logical.Args) {
+	if _, loaded := b.revalidating.LoadOrStore(key, struct{}{}); loaded {
+		return
+	}
+	//后台刷新使用请求的副本，避免与当前请求共享Headers
+	args := *req
+	args.Headers = make(map[string][]string, len(req.Headers))
+	for k, v := range req.Headers {
+		args.Headers[k] = v
+	}
+	args.Stream = nil
+	go func() {
+		defer b.revalidating.Delete(key)
+		defer func() {
+			if r := recover(); r != nil {
+				b.Logger.Error("revalidate cache", "key", key, "err", r)
+			}
+		}()
+		resp := &logical.Reply{}
+		if werr := handler(context.Background(), &args, resp); werr == nil {
+			b.storeCache(settings, key, &args, resp)
+		}
+	}()
+}
+
+func (b *Backend) storeCache(settings *CacheSettings, key string, req *logical.Args, resp *logical.Reply) {
+	if resp.Code != 0 {
+		return
+	}
+	if _, ok := resp.Data.(*logical.Download); ok {
+		return
+	}
+	data, err := json.Marshal(resp.Data)
+	if err != nil {
+		b.Logger.Warn("cache marshal", "key", key, "err", err)
+		return
+	}
+	bs, err := json.Marshal(&cacheEntry{
+		Data:       data,
+		Message:    resp.Message,
+		Pagination: resp.Pagination,
+		FreshUntil: time.Now().Add(settings.TTL).UnixMilli(),
+	})
+	if err != nil {
+		return
+	}
+	tags := settings.Tags
+	if settings.TagsFunc != nil {
+		tags = append(append([]string{}, tags...), settings.TagsFunc(req)...)
+	}
+	if err := b.cache.Set(key, bs, settings.TTL+settings.StaleWhileRevalidate, tags); err != nil {
+		b.Logger.Warn("cache set", "key", key, "err", err)
+	}
+}
+
+//reply 缓存的数据解码为通用结构，数字保留原始精度
+func (e *cacheEntry) reply(resp *logical.Reply) *logical.WrapperError {
+	var data interface{}
+	decoder := json.NewDecoder(bytes.NewReader(e.Data))
+	decoder.UseNumber()
+	_ = decoder.Decode(&data)
+	resp.Data = data
+	resp.Message = e.Message
+	resp.Pagination = e.Pagination
+	return nil
+}
+
+//cacheKey Data按JSON重新编码，对象字段排序、空白移除后计算摘要
+func cacheKey(version string, settings *CacheSettings, req *logical.Args) (string, error) {
+	data, err := req.DataBytes()
+	if err != nil {
+		return "", err
+	}
+	var normalized interface{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&normalized); err != nil {
+			return "", err
+		}
+	}
+	canonical, err := json.Marshal(normalized)
+	if err != nil {
+		return "", err
+	}
+
+	parts := []string{req.Backend, req.Endpoint, req.Operation, version, string(canonical)}
+	if settings.VaryByAccount || (!settings.Shared && req.Authorized != nil) {
+		parts = append(parts, "account="+authorizedID(req))
+	}
+	if settings.VaryByRole {
+		var roles []string
+		if req.Authorized != nil {
+			roles = append(roles, req.Authorized.AccountRoles...)
+		}
+		sort.Strings(roles)
+		parts = append(parts, "roles="+strings.Join(roles, ","))
+	}
+	if settings.VaryByClient {
+		parts = append(parts, "client="+req.GetClientID())
+	}
+	hash := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func authorizedID(req *logical.Args) string {
+	if req.Authorized == nil {
+		return ""
+	}
+	return req.Authorized.ID
+}
+
+type memoryCache struct {
+	sync.Mutex
+	lru  *lru.Cache
+	tags map[string]map[string]struct{}
+}
+
+type memoryCacheEntry struct {
+	value    []byte
+	expireAt time.Time
+	tags     []string
+}
+
+//NewMemoryCache 单节点使用的LRU缓存，size为最多缓存的响应数
+func NewMemoryCache(size int) (ResponseCache, error) {
+	if size <= 0 {
+		size = defaultCacheSize
+	}
+	c := &memoryCache{tags: map[string]map[string]struct{}{}}
+	cache, err := lru.NewWithEvict(size, c.evicted)
+	if err != nil {
+		return nil, err
+	}
+	c.lru = cache
+	return c, nil
+}
+
+//evicted 在持有锁的Add、Remove中回调
+func (c *memoryCache) evicted(key interface{}, value interface{}) {
+	for _, tag := range value.(*memoryCacheEntry).tags {
+		if keys, ok := c.tags[tag]; ok {
+			delete(keys, key.(string))
+			if len(keys) == 0 {
+				delete(c.tags, tag)
+			}
+		}
+	}
+}
+
+func (c *memoryCache) Get(key string) ([]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+	value, ok := c.lru.Get(key)
+	if !ok {
+		return nil, nil
+	}
+	entry := value.(*memoryCacheEntry)
+	if time.Now().After(entry.expireAt) {
+		c.lru.Remove(key)
+		return nil, nil
+	}
+	return entry.value, nil
+}
+
+func (c *memoryCache) Set(key string, value []byte, ttl time.Duration, tags []string) error {
+	c.Lock()
+	defer c.Unlock()
+	c.lru.Remove(key)
+	c.lru.Add(key, &memoryCacheEntry{value: value, expireAt: time.Now().Add(ttl), tags: tags})
+	for _, tag := range tags {
+		if c.tags[tag] == nil {
+			c.tags[tag] = map[string]struct{}{}
+		}
+		c.tags[tag][key] = struct{}{}
+	}
+	return nil
+}
+
+func (c *memoryCache) Invalidate(tags ...string) error {
+	c.Lock()
+	defer c.Unlock()
+	for _, tag := range tags {
+		for key := range c.tags[tag] {
+			c.lru.Remove(key)
+		}
+		delete(c.tags, tag)
+	}
+	return nil
+}
+
+type redisCache struct {
+	redis redisplus.RedisCli
+}
+
+//NewRedisCache 集群使用的redis缓存，标签为记录缓存key的集合
+func NewRedisCache(cli redisplus.RedisCli) ResponseCache {
+	return &redisCache{redis: cli}
+}
+
+func (c *redisCache) cacheKey(key string) string {
+	return strings.Join([]string{"cache", key}, redisplus.RedisKeySep)
+}
+
+func (c *redisCache) tagKey(tag string) string {
+	return strings.Join([]string{"cache", "tag", tag}, redisplus.RedisKeySep)
+}
+
+//Get redisplus的Get不区分key不存在，使用原生命令
+func (c *redisCache) Get(key string) ([]byte, error) {
+	bs, err := c.redis.NativeCmd().Get(strings.Join([]string{c.redis.KeyPrefix(), c.cacheKey(key)}, redisplus.RedisKeySep)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return bs, err
+}
+
+func (c *redisCache) Set(key string, value []byte, ttl time.Duration, tags []string) error {
+	if err := c.redis.Set(c.cacheKey(key), value, ttl.String()); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := c.redis.SAdd(c.tagKey(tag), []byte(c.cacheKey(key))); err != nil {
+			return err
+		}
+		//标签集合的有效期只延长，不短于其中的缓存
+		full := strings.Join([]string{c.redis.KeyPrefix(), c.tagKey(tag)}, redisplus.RedisKeySep)
+		if current, err := c.redis.NativeCmd().TTL(full).Result(); err == nil && current >= ttl {
+			continue
+		}
+		if err := c.redis.Expire(c.tagKey(tag), ttl.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *redisCache) Invalidate(tags ...string) error {
+	for _, tag := range tags {
+		members, err := c.redis.SUnion(c.tagKey(tag))
+		if err != nil {
+			return err
+		}
+		keys := []string{c.tagKey(tag)}
+		for _, member := range members {
+			keys = append(keys, string(member))
+		}
+		if _, err := c.redis.Del(keys...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
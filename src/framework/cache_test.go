@@ -0,0 +1,134 @@
+package framework
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/36625090/involution/authorities"
+	"github.com/36625090/involution/logical"
+	"github.com/go-playground/validator/v10"
+	"github.com/hashicorp/go-hclog"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackend_HandleCached(t *testing.T) {
+	var calls int32
+	backend := &Backend{
+		Name:   "account",
+		Logger: hclog.NewNullLogger(),
+		Config: &logical.BackendContext{},
+		Endpoints: []*Endpoint{{
+			Pattern:     "user",
+			Description: "user",
+			Operations: map[string]OperationHandler{
+				"profile": &EndpointOperation{
+					Description: "profile",
+					Cache:       &CacheSettings{TTL: time.Minute, Tags: []string{"user"}},
+					Callback: func(ctx context.Context, args *logical.Args, reply *logical.Reply) *logical.WrapperError {
+						reply.Data = map[string]interface{}{"calls": atomic.AddInt32(&calls, 1), "id": authorizedID(args)}
+						return nil
+					},
+				},
+			},
+		}},
+		validator: validator.New(),
+	}
+	if err := backend.initCache(); err != nil {
+		t.Fatal(err)
+	}
+
+	handle := func(account string, data string) string {
+		args := &logical.Args{
+			Backend: "account", Endpoint: "user", Operation: "profile",
+			Data:       json.RawMessage(data),
+			Authorized: &authorities.Authorized{ID: account},
+			Headers:    map[string][]string{},
+			Connection: &logical.Connection{RemoteAddr: "127.0.0.1", UserAgent: "test"},
+		}
+		args.SetTraceID("trace")
+		reply, werr := backend.HandleRequest(context.Background(), args)
+		if werr != nil {
+			t.Fatal(werr)
+		}
+		bs, _ := json.Marshal(reply.Data)
+		return string(bs)
+	}
+
+	first := handle("1", `{"a":1,"b":2}`)
+	if cached := handle("1", `{ "b":2, "a":1 }`); cached != first {
+		t.Fatal("normalized data not served from cache", first, cached)
+	}
+	if other := handle("2", `{"a":1,"b":2}`); other == first {
+		t.Fatal("cache should vary by account", other)
+	}
+	if err := backend.InvalidateCache(context.Background(), "user"); err != nil {
+		t.Fatal(err)
+	}
+	if refreshed := handle("1", `{"a":1,"b":2}`); refreshed == first {
+		t.Fatal("cache not invalidated by tag", refreshed)
+	}
+	if atomic.LoadInt32(&calls) != 3 {
+		t.Fatal("unexpected handler calls", calls)
+	}
+}
+
+func TestCacheKey_Account(t *testing.T) {
+	args := func(account string) *logical.Args {
+		req := &logical.Args{Backend: "account", Endpoint: "user", Operation: "profile", Data: json.RawMessage(`{}`)}
+		if account != "" {
+			req.Authorized = &authorities.Authorized{ID: account}
+		}
+		return req
+	}
+	key := func(settings *CacheSettings, account string) string {
+		k, err := cacheKey("", settings, args(account))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	settings := &CacheSettings{TTL: time.Minute}
+	if key(settings, "1") == key(settings, "2") {
+		t.Fatal("authorized accounts must not share cache entries by default")
+	}
+	if key(settings, "") == key(settings, "1") {
+		t.Fatal("anonymous and authorized requests must not share cache entries")
+	}
+	shared := &CacheSettings{TTL: time.Minute, Shared: true}
+	if key(shared, "1") != key(shared, "2") {
+		t.Fatal("shared cache entries must not vary by account")
+	}
+}
+
+func TestBackend_CheckCache(t *testing.T) {
+	callback := func(context.Context, *logical.Args, *logical.Reply) *logical.WrapperError { return nil }
+	check := func(handler OperationHandler) error {
+		backend := &Backend{Name: "account", Endpoints: []*Endpoint{{
+			Pattern:    "user",
+			Operations: map[string]OperationHandler{"profile": handler},
+		}}}
+		return backend.checkEndpoint()
+	}
+
+	if err := check(&EndpointOperation{Callback: callback, Cache: &CacheSettings{TTL: time.Minute}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, settings := range []*CacheSettings{
+		{},
+		{TTL: -time.Second},
+		{TTL: time.Minute, StaleWhileRevalidate: -time.Second},
+	} {
+		if err := check(&EndpointOperation{Callback: callback, Cache: settings}); err == nil {
+			t.Fatal("invalid cache settings accepted", settings)
+		}
+	}
+	versioned := &VersionedOperation{Versions: map[string]OperationHandler{
+		"1.0.0": &EndpointOperation{Callback: callback, Cache: &CacheSettings{}},
+		"2.0.0": &EndpointOperation{Callback: callback},
+	}}
+	if err := check(versioned); err == nil {
+		t.Fatal("invalid cache settings of version accepted")
+	}
+}
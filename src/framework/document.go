@@ -21,23 +21,9 @@ func (b *Backend) initDocuments()error {
 			Operations:  make(map[string]*logical.Operation),
 		}
 		for opt, handler := range ns.Operations {
-			properties := handler.Properties()
-			if properties.Description == "" {
-				return descriptionError(ns.Pattern, opt)
-			}
-			input, err := logical.Fields(properties.Input)
-			if err != nil {
-				return endpointError(ns.Pattern, opt, err)
-			}
-			output, err := logical.Fields(properties.Output)
+			operation, err := operationDocument(ns.Pattern, opt, handler)
 			if err != nil {
-				return endpointError(ns.Pattern, opt, err)
-			}
-			operation := &logical.Operation{
-				Description: properties.Description,
-				Input:       input,
-				Output:      output,
-				Errors:      properties.Errors,
+				return err
 			}
 			endpoint.Operations[opt] = operation
 		}
@@ -47,6 +33,64 @@ func (b *Backend) initDocuments()error {
 	return nil
 }
 
+//operationDocument 生成操作文档，多版本操作以最新版本为主，每个版本单独记录在Versions中
+func operationDocument(pattern string, opt string, handler OperationHandler) (*logical.Operation, error) {
+	versioned, ok := handler.(*VersionedOperation)
+	if !ok {
+		return propertiesDocument(pattern, opt, handler.Properties())
+	}
+
+	versions, err := versioned.sorted()
+	if err != nil {
+		return nil, endpointError(pattern, opt, err)
+	}
+	docs := make(map[string]*logical.Operation)
+	for _, v := range versions {
+		doc, err := propertiesDocument(pattern, opt+"@"+v.name, versioned.Versions[v.name].Properties())
+		if err != nil {
+			return nil, err
+		}
+		doc.Version = v.name
+		docs[v.name] = doc
+	}
+	latest := *docs[versions[len(versions)-1].name]
+	latest.Versions = docs
+	return &latest, nil
+}
+
+func propertiesDocument(pattern string, opt string, properties OperationProperties) (*logical.Operation, error) {
+	if properties.Description == "" {
+		return nil, descriptionError(pattern, opt)
+	}
+	input, err := logical.Fields(properties.Input)
+	if err != nil {
+		return nil, endpointError(pattern, opt, err)
+	}
+	for _, upload := range properties.Uploads {
+		input = append(input, &logical.Field{
+			Field:    upload.Field,
+			Name:     upload.Name,
+			Kind:     logical.FieldKindFile,
+			Required: upload.Required,
+		})
+	}
+	output, err := logical.Fields(properties.Output)
+	if err != nil {
+		return nil, endpointError(pattern, opt, err)
+	}
+	return &logical.Operation{
+		Description: properties.Description,
+		Input:       input,
+		Output:      output,
+		Errors:      properties.Errors,
+		Stream:      properties.Stream,
+		Method:      string(properties.Method),
+		Path:        properties.Path,
+		Idempotent:  properties.Idempotent,
+		Unsigned:    properties.Unsigned,
+	}, nil
+}
+
 func endpointError(pattern string, operation string, err error) error {
 	return fmt.Errorf("endpoint[%s] operation[%s] %s", pattern, operation, err)
 }
@@ -1,6 +1,7 @@
 package framework
 
 import (
+	"context"
 	"github.com/36625090/involution/logical"
 	"reflect"
 	"strings"
@@ -33,6 +34,22 @@ type OperationProperties struct {
 	Input       reflect.Type `json:"-"`
 	Output      reflect.Type `json:"-"`
 	Errors      logical.Errors     `json:"errors"`
+	Stream      bool               `json:"stream"`
+	Method      logical.HttpMethod `json:"method"`
+	Path        string             `json:"path"`
+	Uploads     []*UploadField     `json:"uploads"`
+	Idempotent  bool               `json:"idempotent"`
+	Unsigned    bool               `json:"unsigned"`
+	Cache       *CacheSettings     `json:"cache"`
+}
+
+// UploadField 操作接收的multipart上传文件声明
+type UploadField struct {
+	Field    string
+	Name     string
+	Required bool
+	//MaxSize 文件大小上限(字节)，为0时只受transport的upload_max_size限制
+	MaxSize int64
 }
 
 
@@ -43,6 +60,19 @@ type EndpointOperation struct {
 	Input       reflect.Type
 	Output      reflect.Type
 	Errors      logical.Errors
+	//Method 可选的RESTful路由HTTP方法，与Path同时设置
+	Method logical.HttpMethod
+	//Path 可选的RESTful路由路径模板，如 /account/user/{id}
+	//路径、查询和请求体参数合并后作为Args.Data
+	Path string
+	//Uploads 接收的上传文件，未声明的文件会被拒绝
+	Uploads []*UploadField
+	//Idempotent 为true时按请求头X-Idempotency-Key、客户端ID和账户ID保存首次签名响应，重复请求复用该响应
+	Idempotent bool
+	//Unsigned 为true时JSON-RPC和RESTful路由调用可以不签名，仅依赖令牌鉴权；请求信封接口不受影响
+	Unsigned bool
+	//Cache 响应缓存，为nil时不缓存
+	Cache *CacheSettings
 }
 
 func (p *EndpointOperation) Handler() OperationFunc {
@@ -55,6 +85,56 @@ func (p *EndpointOperation) Properties() OperationProperties {
 		Input:       p.Input,
 		Output:      p.Output,
 		Errors:      p.Errors,
+		Method:      p.Method,
+		Path:        p.Path,
+		Uploads:     p.Uploads,
+		Idempotent:  p.Idempotent,
+		Unsigned:    p.Unsigned,
+		Cache:       p.Cache,
+	}
+}
+
+
+// StreamOperation is a streaming implementation of OperationHandler.
+// 客户端以 Accept: text/event-stream 请求时通过SSE推送进度和部分结果，最后一帧为签名后的响应
+// 非流式请求时推送被忽略，只返回最终结果
+type StreamOperation struct {
+	Callback    StreamOperationFunc
+	Description string
+	Input       reflect.Type
+	Output      reflect.Type
+	Errors      logical.Errors
+}
+
+func (p *StreamOperation) Handler() OperationFunc {
+	if p.Callback == nil {
+		return nil
+	}
+	return func(ctx context.Context, args *logical.Args, reply *logical.Reply) *logical.WrapperError {
+		stream := args.Stream
+		if stream == nil {
+			stream = discardStream{}
+		}
+		return p.Callback(ctx, args, stream, reply)
 	}
 }
 
+func (p *StreamOperation) Properties() OperationProperties {
+	return OperationProperties{
+		Description: strings.TrimSpace(p.Description),
+		Input:       p.Input,
+		Output:      p.Output,
+		Errors:      p.Errors,
+		Stream:      true,
+	}
+}
+
+type discardStream struct{}
+
+func (discardStream) Progress(int, string) error {
+	return nil
+}
+
+func (discardStream) Send(string, interface{}) error {
+	return nil
+}
@@ -0,0 +1,155 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrVersionNotSupported = errors.New("operation version not supported")
+
+//Version 语义化版本 major.minor.patch，允许v前缀及省略minor、patch
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+//ParseVersion 解析语义化版本，预发布和构建元数据(-、+之后的部分)被忽略
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	raw := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(raw, "-+"); i >= 0 {
+		raw = raw[:i]
+	}
+	parts := strings.Split(raw, ".")
+	if raw == "" || len(parts) > 3 {
+		return v, fmt.Errorf("invalid version: %q", s)
+	}
+	numbers := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version: %q", s)
+		}
+		*numbers[i] = n
+	}
+	return v, nil
+}
+
+//Compare 比较版本，返回-1、0、1
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+//VersionedOperation 同一操作的多个版本，key为语义化版本
+//按请求信封的version选择：版本相同时直接使用，否则使用主版本相同且不高于请求版本的最新版本；
+//请求未携带版本(如REST、JSON-RPC)时使用最新版本
+//RESTful路由只使用最新版本声明的Method和Path
+type VersionedOperation struct {
+	Versions map[string]OperationHandler
+}
+
+//Handler 最新版本的处理函数
+func (p *VersionedOperation) Handler() OperationFunc {
+	_, latest, err := p.Select("")
+	if err != nil {
+		return nil
+	}
+	return latest.Handler()
+}
+
+//Properties 最新版本的属性
+func (p *VersionedOperation) Properties() OperationProperties {
+	_, latest, err := p.Select("")
+	if err != nil {
+		return OperationProperties{}
+	}
+	return latest.Properties()
+}
+
+//Select 按请求版本选择操作，返回选中的版本
+func (p *VersionedOperation) Select(version string) (string, OperationHandler, error) {
+	versions, err := p.sorted()
+	if err != nil {
+		return "", nil, err
+	}
+	if len(versions) == 0 {
+		return "", nil, ErrVersionNotSupported
+	}
+	if version == "" {
+		latest := versions[len(versions)-1]
+		return latest.name, p.Versions[latest.name], nil
+	}
+
+	requested, err := ParseVersion(version)
+	if err != nil {
+		return "", nil, err
+	}
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		if v.Major == requested.Major && v.Compare(requested) <= 0 {
+			return v.name, p.Versions[v.name], nil
+		}
+	}
+	return "", nil, fmt.Errorf("%w: %s", ErrVersionNotSupported, version)
+}
+
+type namedVersion struct {
+	Version
+	name string
+}
+
+//sorted 按版本从低到高排序
+func (p *VersionedOperation) sorted() ([]namedVersion, error) {
+	versions := make([]namedVersion, 0, len(p.Versions))
+	for name := range p.Versions {
+		v, err := ParseVersion(name)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, namedVersion{Version: v, name: name})
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Compare(versions[j].Version) < 0
+	})
+	return versions, nil
+}
+
+//check 校验版本声明
+func (p *VersionedOperation) check() error {
+	versions, err := p.sorted()
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return errors.New("at least one version required")
+	}
+	for i, v := range versions {
+		if i > 0 && v.Compare(versions[i-1].Version) == 0 {
+			return fmt.Errorf("duplicate version: %s and %s", versions[i-1].name, v.name)
+		}
+		handler := p.Versions[v.name]
+		if _, ok := handler.(*VersionedOperation); ok {
+			return fmt.Errorf("version %s cannot be nested", v.name)
+		}
+		if handler == nil || handler.Handler() == nil {
+			return fmt.Errorf("version %s callback cannot be nil", v.name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"github.com/36625090/involution/logical"
+	"testing"
+)
+
+func TestVersionedOperation_Select(t *testing.T) {
+	callback := func(context.Context, *logical.Args, *logical.Reply) *logical.WrapperError { return nil }
+	operation := &VersionedOperation{Versions: map[string]OperationHandler{
+		"1.0.0": &EndpointOperation{Callback: callback, Description: "v1"},
+		"1.2":   &EndpointOperation{Callback: callback, Description: "v1.2"},
+		"2.0.0": &EndpointOperation{Callback: callback, Description: "v2"},
+	}}
+	if err := operation.check(); err != nil {
+		t.Fatal(err)
+	}
+
+	for requested, expected := range map[string]string{
+		"":       "2.0.0",
+		"1.0.0":  "1.0.0",
+		"1.1.5":  "1.0.0",
+		"v1.2.0": "1.2",
+		"1.9":    "1.2",
+		"2.3.1":  "2.0.0",
+	} {
+		selected, _, err := operation.Select(requested)
+		if err != nil || selected != expected {
+			t.Fatalf("version %q selected %q, expected %q: %v", requested, selected, expected, err)
+		}
+	}
+
+	if _, _, err := operation.Select("0.9"); !errors.Is(err, ErrVersionNotSupported) {
+		t.Fatal("unexpected selection of 0.9", err)
+	}
+	if _, _, err := operation.Select("x.1"); err == nil {
+		t.Fatal("invalid version accepted")
+	}
+	if operation.Properties().Description != "v2" {
+		t.Fatal("properties should describe the latest version")
+	}
+
+	doc, err := operationDocument("user", "login", operation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != "2.0.0" || len(doc.Versions) != 3 || doc.Versions["1.2"].Description != "v1.2" {
+		t.Fatal("unexpected versioned document", doc)
+	}
+}
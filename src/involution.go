@@ -28,12 +28,18 @@ type Involution interface {
 	//AddHandle 添加http方法
 	AddHandle(path string, method logical.HttpMethod, handle func(*transport.Context, *server.HandlerParams) error)
 
+	//AddMiddleware 添加请求信封中间件，在验签之后、响应签名之前按添加顺序执行
+	AddMiddleware(middlewares ...transport.Middleware)
+
 	//RegisterBackend 注册后端逻辑端点
 	RegisterBackend(string, logical.Factory, *logical.BackendContext) error
 
 	//RegisterAuthorization 注册验证接口，如微注册则不验证
 	RegisterAuthorization(authorization authorities.Authorization) error
 
+	//Pusher 向指定账户的WebSocket连接推送消息
+	Pusher() logical.Pusher
+
 	//Start 启动服务
 	Start() error
 	Stop()
@@ -87,10 +93,14 @@ func DefaultInvolution(opts *option.Options, factories map[string]logical.Factor
 		}
 	}
 
-	inv := server.NewServer(opts, globalConfig, client, logger)
+	inv, err := server.NewServer(opts, globalConfig, client, logger)
+	if err != nil {
+		return nil, err
+	}
 	if err := inv.RegisterAuthorization(authorization); err != nil {
 		return nil, err
 	}
+	context.Pusher = inv.Pusher()
 
 	for name, factory := range factories {
 		if err := inv.RegisterBackend(name, factory, context); err != nil {
@@ -2,9 +2,11 @@ package logical
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/36625090/involution/authorities"
 	"github.com/36625090/involution/utils"
 	"github.com/go-playground/validator/v10"
+	"net/http"
 )
 
 var validate *validator.Validate
@@ -17,11 +19,17 @@ type Args struct {
 	Backend    string                  `json:"backend" validate:"required"`
 	Endpoint   string                  `json:"endpoint" validate:"required"`
 	Operation  string                  `json:"operation" validate:"required"`
+	//Version 客户端请求的接口版本，用于选择多版本操作
+	Version    string                  `json:"version,omitempty"`
 	Data       interface{}             `json:"data" validate:"required"`
 	Authorized *authorities.Authorized `json:"authorized"`
 	Token      string                  `json:"token"`
 	Headers    map[string][]string     `json:"headers"`
 	Connection *Connection             `json:"connection" validate:"required"`
+	//Stream 客户端请求流式响应时不为nil
+	Stream StreamWriter `json:"-"`
+	//Files multipart请求上传的文件
+	Files []*File `json:"files,omitempty"`
 }
 
 func (r *Args) GetTraceID() string {
@@ -37,13 +45,83 @@ func (r *Args) SetTraceID(id string) *Args {
 	return r
 }
 
+//SetTraceHeaders 从请求头复制链路追踪请求头，参考TraceHeaders
+func (r *Args) SetTraceHeaders(header http.Header) *Args {
+	for _, key := range TraceHeaders {
+		if value := header.Get(key.String()); value != "" {
+			r.Headers[key.String()] = []string{value}
+		}
+	}
+	return r
+}
+
+//TraceHeaders 调用其他服务(如micro)时需要传递的链路追踪请求头，framework.Backend.RestyClient的请求自动传递
+//  request.SetHeaders(args.TraceHeaders())
+func (r *Args) TraceHeaders() map[string]string {
+	headers := make(map[string]string)
+	for _, key := range TraceHeaders {
+		if values := r.Headers[key.String()]; len(values) > 0 && values[0] != "" {
+			headers[key.String()] = values[0]
+		}
+	}
+	return headers
+}
+
+//GetClientID 客户端请求头X-Client-ID
+func (r *Args) GetClientID() string {
+	clients, ok := r.Headers[string(HeaderClientIDKey)]
+	if ok && len(clients) > 0 {
+		return clients[0]
+	}
+	return ""
+}
+
+func (r *Args) SetClientID(id string) *Args {
+	if id != "" {
+		r.Headers[string(HeaderClientIDKey)] = []string{id}
+	}
+	return r
+}
+
+//ShouldBindJSON 将Data绑定到out并校验
+//Data 可以是JSON字符串、[]byte、json.RawMessage或任意可JSON序列化的结构
 func (r *Args) ShouldBindJSON(out interface{}) error {
-	if err := json.Unmarshal([]byte(r.Data.(string)), out); err != nil {
+	data, err := r.DataBytes()
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
 		return err
 	}
 	return validate.Struct(out)
 }
 
+//DataBytes 返回Data的JSON
+func (r *Args) DataBytes() ([]byte, error) {
+	switch data := r.Data.(type) {
+	case nil:
+		return nil, errors.New("data is empty")
+	case string:
+		return []byte(data), nil
+	case []byte:
+		return data, nil
+	case json.RawMessage:
+		return data, nil
+	default:
+		return json.Marshal(data)
+	}
+}
+
+//File 返回字段名对应的上传文件，不存在时返回nil
+func (r *Args) File(field string) *File {
+	for _, file := range r.Files {
+		if file.Field == field {
+			return file
+		}
+	}
+	return nil
+}
+
 func (r *Args) String()string  {
 	return utils.JSONDump(r)
 }
\ No newline at end of file
@@ -19,6 +19,7 @@ type BackendContext struct {
 	AuthSettings *authorities.Settings    `json:"authorization" hcl:"authorization,block"`
 	Consul       consul.Client            `json:"-"`
 	TokenHandler authorities.TokenHandler `json:"-"`
+	Pusher       Pusher                   `json:"-"`
 }
 
 func (m *BackendContext) Clone() *BackendContext {
@@ -29,6 +30,7 @@ func (m *BackendContext) Clone() *BackendContext {
 		XormConfig:   m.XormConfig,
 		RedisConfig:  m.RedisConfig,
 		AuthSettings: m.AuthSettings,
+		Pusher:       m.Pusher,
 	}
 }
 
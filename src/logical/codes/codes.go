@@ -24,6 +24,14 @@ const (
 	CodeInvalidRequestParameter ReturnCode = 1009
 	CodeBindRequestData         ReturnCode = 1010
 	CodeHandleRequest           ReturnCode = 1011
+	CodeReplayedRequest         ReturnCode = 1012
+	CodeBatchAborted            ReturnCode = 1013
+	CodeInvalidEncryption       ReturnCode = 1014
+	CodeInvalidUpload           ReturnCode = 1015
+	CodeDownloadUnsupported     ReturnCode = 1016
+	CodeUnsupportedVersion      ReturnCode = 1017
+	CodeIdempotencyConflict     ReturnCode = 1018
+	CodeReadRequestBody         ReturnCode = 1019
 
 	CodeFailedDecodeArgs ReturnCode = 2001
 	CodeServiceException ReturnCode = 3001
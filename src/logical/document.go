@@ -26,6 +26,14 @@ type Operation struct {
 	Input       []*Field `json:"input,omitempty"`
 	Output      []*Field `json:"output,omitempty"`
 	Errors      Errors   `json:"errors,omitempty"`
+	Stream      bool     `json:"stream,omitempty"`
+	Method      string   `json:"method,omitempty"`
+	Path        string   `json:"path,omitempty"`
+	Idempotent  bool     `json:"idempotent,omitempty"`
+	Unsigned    bool     `json:"unsigned,omitempty"`
+	//Version 多版本操作的版本，Versions为全部版本的文档，顶层为最新版本
+	Version  string                `json:"version,omitempty"`
+	Versions map[string]*Operation `json:"versions,omitempty"`
 }
 
 //Field
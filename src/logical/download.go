@@ -0,0 +1,40 @@
+package logical
+
+import (
+	"io"
+)
+
+//Download 文件下载响应，作为Reply.Data返回时响应体为文件内容而不是JSON信封
+//签名和trace-id通过响应头返回，参考transport/download.go
+type Download struct {
+	Filename    string
+	ContentType string
+	//Inline 为true时Content-Disposition为inline，浏览器直接展示
+	Inline bool
+	//Body 文件内容，响应写出后由框架关闭
+	Body io.ReadCloser
+}
+
+//NewDownload 创建附件下载响应，contentType为空时使用application/octet-stream
+func NewDownload(filename, contentType string, body io.ReadCloser) *Download {
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return &Download{Filename: filename, ContentType: contentType, Body: body}
+}
+
+//Disposition Content-Disposition的类型
+func (d *Download) Disposition() string {
+	if d.Inline {
+		return "inline"
+	}
+	return "attachment"
+}
+
+//Close 关闭文件内容
+func (d *Download) Close() error {
+	if d.Body == nil {
+		return nil
+	}
+	return d.Body.Close()
+}
@@ -0,0 +1,34 @@
+package logical
+
+import (
+	"errors"
+	"io"
+)
+
+//FieldKindFile 文档中上传文件字段的类型
+const FieldKindFile = "file"
+
+//File 上传的文件，内容已按签名中的摘要校验
+type File struct {
+	//Field 表单字段名
+	Field       string `json:"field"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	//Hash 内容的SHA256摘要(hex)
+	Hash string `json:"hash"`
+	open func() (io.ReadCloser, error)
+}
+
+//NewFile 创建上传文件，open每次调用返回从头读取的内容
+func NewFile(field, filename, contentType string, size int64, hash string, open func() (io.ReadCloser, error)) *File {
+	return &File{Field: field, Filename: filename, ContentType: contentType, Size: size, Hash: hash, open: open}
+}
+
+//Open 以流的方式读取文件内容，调用方负责关闭
+func (f *File) Open() (io.ReadCloser, error) {
+	if f.open == nil {
+		return nil, errors.New("file content unavailable: " + f.Field)
+	}
+	return f.open()
+}
@@ -7,9 +7,21 @@ func (h HeaderKey) String() string {
 }
 
 const (
-	HeaderTraceIDKey       HeaderKey = "X-Trace-ID"
-	HeaderClientIDKey      HeaderKey = "X-Client-ID"
-	HeaderApplicationKey   HeaderKey = "X-Application"
-	HeaderAuthorizationKey HeaderKey = "Authorization"
+	HeaderTraceIDKey        HeaderKey = "X-Trace-ID"
+	HeaderClientIDKey       HeaderKey = "X-Client-ID"
+	HeaderApplicationKey    HeaderKey = "X-Application"
+	HeaderAuthorizationKey  HeaderKey = "Authorization"
+	HeaderSignKeyVersionKey HeaderKey = "X-Sign-Key-Version"
+	HeaderSignVersionKey    HeaderKey = "X-Sign-Version"
+	HeaderBatchAbortKey     HeaderKey = "X-Batch-Abort"
+	HeaderIdempotencyKey    HeaderKey = "X-Idempotency-Key"
+	//下载响应及RESTful请求的签名信息
+	HeaderSignKey          HeaderKey = "X-Sign"
+	HeaderSignTypeKey      HeaderKey = "X-Sign-Type"
+	HeaderTimestampKey     HeaderKey = "X-Timestamp"
+	HeaderNonceKey         HeaderKey = "X-Nonce"
+	HeaderContentSHA256Key HeaderKey = "X-Content-SHA256"
+	//W3C Trace Context，参考TraceContext
+	HeaderTraceparentKey HeaderKey = "traceparent"
+	HeaderTracestateKey  HeaderKey = "tracestate"
 )
-
@@ -0,0 +1,20 @@
+package logical
+
+import (
+	"context"
+	"encoding/json"
+)
+
+//Pusher 向指定账户的WebSocket连接推送消息
+//id 为 authorities.Authorized.ID，集群部署时消息经redis发布订阅扇出到所有实例
+type Pusher interface {
+	Push(ctx context.Context, id string, event string, data interface{}) error
+}
+
+//PushMessage 推送消息
+type PushMessage struct {
+	ID        string          `json:"id"`
+	Event     string          `json:"event"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Timestamp int64           `json:"timestamp"`
+}
@@ -0,0 +1,9 @@
+package logical
+
+//StreamWriter 流式响应接口，长时间运行的操作通过它向客户端推送进度和部分结果
+type StreamWriter interface {
+	//Progress 推送进度 percent 0-100
+	Progress(percent int, message string) error
+	//Send 推送部分结果，event为事件名称
+	Send(event string, data interface{}) error
+}
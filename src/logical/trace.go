@@ -0,0 +1,176 @@
+package logical
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+)
+
+const (
+	//traceparentVersion 生成的traceparent版本
+	traceparentVersion = "00"
+	//traceFlagsSampled 生成的traceparent默认标记为采样
+	traceFlagsSampled = "01"
+	//maxTraceIDLength X-Trace-ID的最大长度
+	maxTraceIDLength = 128
+	//maxTracestateLength tracestate的最大长度，超出时丢弃
+	maxTracestateLength = 512
+)
+
+var errInvalidTraceparent = errors.New("invalid traceparent")
+
+//TraceHeaders 链路追踪请求头，调用其他服务时需要传递
+var TraceHeaders = []HeaderKey{HeaderTraceIDKey, HeaderTraceparentKey, HeaderTracestateKey}
+
+type traceHeadersKey struct{}
+
+//WithTraceHeaders 将链路追踪请求头放入上下文，调用其他服务的客户端从上下文读取后自动传递
+func WithTraceHeaders(ctx context.Context, headers map[string]string) context.Context {
+	return context.WithValue(ctx, traceHeadersKey{}, headers)
+}
+
+//TraceHeadersFromContext 上下文中的链路追踪请求头，参考WithTraceHeaders
+func TraceHeadersFromContext(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(traceHeadersKey{}).(map[string]string)
+	return headers
+}
+
+//TraceContext 请求的链路信息，参考W3C Trace Context https://www.w3.org/TR/trace-context/
+//ID 为X-Trace-ID，TraceID SpanID Flags 为本服务的traceparent，ParentID 为上游的parent-id
+type TraceContext struct {
+	ID       string
+	TraceID  string
+	SpanID   string
+	ParentID string
+	Flags    string
+	State    string
+}
+
+//NewTraceContext 生成新的链路，X-Trace-ID为uuid，trace-id为其十六进制形式
+func NewTraceContext() *TraceContext {
+	id := uuid.New()
+	return &TraceContext{
+		ID:      id.String(),
+		TraceID: hex.EncodeToString(id[:]),
+		SpanID:  newSpanID(),
+		Flags:   traceFlagsSampled,
+	}
+}
+
+//ResolveTrace 从请求头解析上游链路并为本服务生成新的span
+//合法的X-Trace-ID保留，traceparent合法时沿用其trace-id和flags，tracestate仅在traceparent合法时保留
+//未携带traceparent时trace-id由X-Trace-ID(32位十六进制或uuid)转换，否则重新生成
+func ResolveTrace(header http.Header) *TraceContext {
+	id := header.Get(HeaderTraceIDKey.String())
+	if !ValidTraceID(id) {
+		id = ""
+	}
+
+	if parent, err := ParseTraceparent(header.Get(HeaderTraceparentKey.String())); err == nil {
+		trace := &TraceContext{
+			ID:       id,
+			TraceID:  parent.TraceID,
+			SpanID:   newSpanID(),
+			ParentID: parent.SpanID,
+			Flags:    parent.Flags,
+		}
+		if state := header.Get(HeaderTracestateKey.String()); len(state) <= maxTracestateLength {
+			trace.State = state
+		}
+		if trace.ID == "" {
+			trace.ID = trace.TraceID
+		}
+		return trace
+	}
+
+	trace := NewTraceContext()
+	if id != "" {
+		trace.ID = id
+		if traceID := strings.ToLower(strings.ReplaceAll(id, "-", "")); validHex(traceID, 32) {
+			trace.TraceID = traceID
+		}
+	}
+	return trace
+}
+
+//ParseTraceparent 解析traceparent，SpanID为上游的parent-id
+func ParseTraceparent(value string) (*TraceContext, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 {
+		return nil, errInvalidTraceparent
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isHex(version, 2) || version == "ff" {
+		return nil, errInvalidTraceparent
+	}
+	//版本00只有4段，更高版本按前4段解析
+	if version == traceparentVersion && len(parts) != 4 {
+		return nil, errInvalidTraceparent
+	}
+	if !validHex(traceID, 32) || !validHex(spanID, 16) || !isHex(flags, 2) {
+		return nil, errInvalidTraceparent
+	}
+	return &TraceContext{TraceID: traceID, SpanID: spanID, Flags: flags}, nil
+}
+
+//ValidTraceID X-Trace-ID是否合法，长度不超过128，只包含字母、数字和 - _ . :
+func ValidTraceID(id string) bool {
+	if id == "" || len(id) > maxTraceIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+//Traceparent 本服务的traceparent
+func (t *TraceContext) Traceparent() string {
+	return traceparentVersion + "-" + t.TraceID + "-" + t.SpanID + "-" + t.Flags
+}
+
+//Inject 将链路信息写入请求头或响应头
+func (t *TraceContext) Inject(header http.Header) {
+	header.Set(HeaderTraceIDKey.String(), t.ID)
+	header.Set(HeaderTraceparentKey.String(), t.Traceparent())
+	if t.State != "" {
+		header.Set(HeaderTracestateKey.String(), t.State)
+	} else {
+		header.Del(HeaderTracestateKey.String())
+	}
+}
+
+//validHex 是否为指定长度的小写十六进制且不全为0
+func validHex(s string, length int) bool {
+	return isHex(s, length) && strings.Trim(s, "0") != ""
+}
+
+//isHex 是否为指定长度的小写十六进制
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9', c >= 'a' && c <= 'f':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func newSpanID() string {
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
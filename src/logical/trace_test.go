@@ -0,0 +1,67 @@
+package logical
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	valid := map[string]string{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01":     "00f067aa0ba902b7",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-ext": "00f067aa0ba902b7",
+	}
+	for value, spanID := range valid {
+		trace, err := ParseTraceparent(value)
+		if err != nil || trace.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || trace.SpanID != spanID {
+			t.Fatal("unexpected traceparent", value, trace, err)
+		}
+	}
+
+	for _, value := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-ext",
+	} {
+		if _, err := ParseTraceparent(value); err == nil {
+			t.Fatal("invalid traceparent accepted", value)
+		}
+	}
+}
+
+func TestResolveTrace(t *testing.T) {
+	header := http.Header{}
+	header.Set(HeaderTraceIDKey.String(), "gateway-123")
+	header.Set(HeaderTraceparentKey.String(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set(HeaderTracestateKey.String(), "vendor=value")
+	trace := ResolveTrace(header)
+	if trace.ID != "gateway-123" || trace.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		trace.ParentID != "00f067aa0ba902b7" || trace.SpanID == trace.ParentID || trace.State != "vendor=value" {
+		t.Fatal("unexpected trace", trace)
+	}
+	trace.Inject(header)
+	if header.Get(HeaderTraceparentKey.String()) != "00-4bf92f3577b34da6a3ce929d0e0e4736-"+trace.SpanID+"-01" {
+		t.Fatal("unexpected traceparent", header)
+	}
+
+	//无traceparent时trace-id由uuid格式的X-Trace-ID转换，tracestate丢弃
+	header = http.Header{}
+	header.Set(HeaderTraceIDKey.String(), "4bf92f35-77b3-4da6-a3ce-929d0e0e4736")
+	header.Set(HeaderTracestateKey.String(), "vendor=value")
+	trace = ResolveTrace(header)
+	if trace.ID != "4bf92f35-77b3-4da6-a3ce-929d0e0e4736" || trace.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || trace.State != "" {
+		t.Fatal("unexpected trace", trace)
+	}
+
+	//非法的X-Trace-ID重新生成
+	header = http.Header{}
+	header.Set(HeaderTraceIDKey.String(), "bad id\n"+strings.Repeat("x", 10))
+	trace = ResolveTrace(header)
+	if !ValidTraceID(trace.ID) || trace.ID == header.Get(HeaderTraceIDKey.String()) || len(trace.TraceID) != 32 {
+		t.Fatal("invalid trace id kept", trace)
+	}
+}
@@ -15,19 +15,37 @@ type Consul struct {
 }
 
 type Http struct {
-	Path         string `long:"http.path" default:"" description:"Path for http server context"`
-	Address      string `long:"http.address" default:"0.0.0.0" description:"Address for http server listening"`
-	Port         int    `long:"http.port" default:"8080" description:"Port for http server listening"`
-	Cors         bool   `long:"http.cors" description:"Support Cors access"`
-	Trace        bool   `long:"http.trace" description:"Trace http requests"`
-	Sign         bool   `long:"http.sign" description:"Sign verification requests"`
-	IdleTimeout  int    `long:"http.idle" default:"30"  description:"Timeout(seconds) for idle connection"`
-	ReadTimeout  int    `long:"http.read" default:"5" description:"Timeout(seconds) for read  client request"`
-	WriteTimeout int    `long:"http.write" default:"10" description:"Timeout(seconds) for write to client request"`
-	KeepAlive    bool   `long:"http.keepalive" description:"Keep-Alive"`
+	Path          string `long:"http.path" default:"" description:"Path for http server context"`
+	Address       string `long:"http.address" default:"0.0.0.0" description:"Address for http server listening"`
+	Port          int    `long:"http.port" default:"8080" description:"Port for http server listening"`
+	Cors          bool   `long:"http.cors" description:"Support Cors access"`
+	Trace         bool   `long:"http.trace" description:"Trace http requests"`
+	Sign          bool   `long:"http.sign" description:"Sign verification requests"`
+	IdleTimeout   int    `long:"http.idle" default:"30"  description:"Timeout(seconds) for idle connection"`
+	ReadTimeout   int    `long:"http.read" default:"5" description:"Timeout(seconds) for read  client request"`
+	WriteTimeout  int    `long:"http.write" default:"10" description:"Timeout(seconds) for write to client request"`
+	StreamTimeout int    `long:"http.stream" default:"600" description:"Timeout(seconds) for write to client streaming(text/event-stream) request"`
+	KeepAlive     bool   `long:"http.keepalive" description:"Keep-Alive"`
+	WebSocket     bool   `long:"http.websocket" description:"Enable websocket channel on /ws"`
+	JSONRPC       bool   `long:"http.jsonrpc" description:"Enable JSON-RPC 2.0 endpoint on /jsonrpc"`
+	WebSocketPush string `long:"http.websocket.push" default:"memory" choice:"memory" choice:"redis" description:"Broker for websocket push across instances"`
 }
 
-//Log logging settings
+type Grpc struct {
+	Address string `long:"grpc.address" default:"0.0.0.0" description:"Address for grpc server listening"`
+	Port    int    `long:"grpc.port" default:"9090" description:"Port for grpc server listening"`
+}
+
+// Otel OpenTelemetry tracing settings
+type Otel struct {
+	Exporter    string  `long:"otel.exporter" default:"otlp-grpc" choice:"otlp-grpc" choice:"otlp-http" choice:"stdout" choice:"file" description:"Exporter for spans"`
+	Endpoint    string  `long:"otel.endpoint" description:"Endpoint(host:port) for otlp exporter, defaults to OTEL_EXPORTER_OTLP_ENDPOINT"`
+	Insecure    bool    `long:"otel.insecure" description:"Disable TLS for otlp exporter"`
+	File        string  `long:"otel.file" default:"logs/traces.json" description:"File for file exporter"`
+	SampleRatio float64 `long:"otel.sample" default:"1" description:"Sample ratio for requests without sampled upstream"`
+}
+
+// Log logging settings
 type Log struct {
 	Console bool   `long:"log.console" description:"Set log output to console"`
 	Path    string `long:"log.path" default:"logs" required:"true" description:"Sets the path to log file"`
@@ -36,7 +54,7 @@ type Log struct {
 	Rotate  string `long:"log.rotate" default:"day" description:"Rotates the log" choice:"day" choice:"hour" `
 }
 
-//Options 服务参数选项（OOPs 英语不好，注释描述凑合看，写中文怕终端乱码 ^ - ^）
+// Options 服务参数选项（OOPs 英语不好，注释描述凑合看，写中文怕终端乱码 ^ - ^）
 type Options struct {
 	App           string `long:"app" required:"true" description:"App name for service"`
 	Profile       string `long:"profile" description:" Profile for runtime"`
@@ -45,12 +63,16 @@ type Options struct {
 	UseConsul     bool   `long:"consul" description:"Enable consul"`
 	Consul        Consul `group:"consul"`
 	Http          Http   `group:"http"`
+	UseGrpc       bool   `long:"grpc" description:"Enable grpc server"`
+	Grpc          Grpc   `group:"grpc"`
 	Pprof         bool   `long:"pprof" description:"Enable profiling"`
 	PprofAddr     string `long:"pprof.port" default:"127.0.0.1:32768" description:"Listen port on Pprof server"`
 	Ui            bool   `long:"ui" description:"Enable document ui support"`
 	Newrelic      bool   `long:"newrelic" description:"Enable newrelic support"`
 	NewrelicKey   string `long:"newrelic.key" description:"Key for newrelic access"`
 	NewrelicTrace bool   `long:"newrelic.trace" description:"Trace on newrelic access"`
+	UseOtel       bool   `long:"otel" description:"Enable OpenTelemetry tracing"`
+	Otel          Otel   `group:"otel"`
 }
 
 var opts Options
@@ -11,6 +11,10 @@ func Cors() gin.HandlerFunc {
 	headers := []string{
 		"Origin", "Authorization", "Content-Type",
 		string(logical.HeaderTraceIDKey), string(logical.HeaderApplicationKey),string(logical.HeaderClientIDKey),
+		string(logical.HeaderSignKeyVersionKey), string(logical.HeaderBatchAbortKey), string(logical.HeaderIdempotencyKey),
+		string(logical.HeaderTraceparentKey), string(logical.HeaderTracestateKey),
+		string(logical.HeaderSignKey), string(logical.HeaderSignTypeKey),
+		string(logical.HeaderTimestampKey), string(logical.HeaderNonceKey),
 		"Os-Version", "App-Version", "Location",
 	}
 	mwCORS := cors.New(cors.Config{
@@ -21,7 +25,10 @@ func Cors() gin.HandlerFunc {
 		//准许使用的请求表头
 		AllowHeaders: headers,
 		//显示的请求表头
-		ExposeHeaders: []string{"Content-Type"},
+		ExposeHeaders: []string{"Content-Type", "Content-Disposition", string(logical.HeaderSignKeyVersionKey),
+			string(logical.HeaderTraceIDKey), string(logical.HeaderTraceparentKey), string(logical.HeaderTracestateKey),
+			string(logical.HeaderSignKey), string(logical.HeaderTimestampKey), string(logical.HeaderContentSHA256Key),
+			string(logical.HeaderSignVersionKey)},
 		//凭证共享,确定共享
 		AllowCredentials: true,
 		//超时时间设定
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"github.com/36625090/involution/logical"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//corsRequest 发送跨域请求，method为OPTIONS时为预检请求，返回响应头
+func corsRequest(method string) http.Header {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(Cors())
+	engine.POST("/api", func(c *gin.Context) {})
+	req := httptest.NewRequest(method, "/api", nil)
+	req.Header.Set("Origin", "http://app.example.org")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w.Header()
+}
+
+//hasHeader 逗号分隔的header列表中是否包含key，不区分大小写
+func hasHeader(list string, key logical.HeaderKey) bool {
+	for _, name := range strings.Split(list, ",") {
+		if strings.EqualFold(strings.TrimSpace(name), key.String()) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCors_AllowHeaders(t *testing.T) {
+	allowed := corsRequest(http.MethodOptions).Get("Access-Control-Allow-Headers")
+	for _, key := range []logical.HeaderKey{
+		logical.HeaderIdempotencyKey,
+		logical.HeaderSignKey, logical.HeaderSignTypeKey, logical.HeaderTimestampKey, logical.HeaderNonceKey,
+	} {
+		if !hasHeader(allowed, key) {
+			t.Fatal("header not allowed", key, allowed)
+		}
+	}
+}
+
+func TestCors_ExposeHeaders(t *testing.T) {
+	exposed := corsRequest(http.MethodPost).Get("Access-Control-Expose-Headers")
+	for _, key := range []logical.HeaderKey{
+		logical.HeaderSignKey, logical.HeaderTimestampKey, logical.HeaderContentSHA256Key, logical.HeaderSignVersionKey,
+	} {
+		if !hasHeader(exposed, key) {
+			t.Fatal("header not exposed", key, exposed)
+		}
+	}
+}
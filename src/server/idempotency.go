@@ -0,0 +1,33 @@
+package server
+
+import (
+	"fmt"
+	"github.com/36625090/involution/authorities"
+	"github.com/36625090/involution/config"
+	"github.com/36625090/involution/transport"
+	"strings"
+)
+
+//newIdempotencyStore 幂等请求的存储，默认使用redis，多实例部署时内存存储无法识别其他实例处理过的请求
+func newIdempotencyStore(name string, cfg *config.GlobalConfig) (transport.IdempotencyStore, error) {
+	switch name {
+	case "", transport.IdempotencyStoreRedis:
+		return transport.NewRedisIdempotencyStore(cfg.RedisConfig)
+	case transport.IdempotencyStoreMemory:
+		return transport.NewMemoryIdempotencyStore(), nil
+	}
+	return nil, fmt.Errorf("unsupported idempotency store: %s", name)
+}
+
+//idempotent 幂等操作按 客户端ID:账户ID:X-Idempotency-Key 复用首次请求的签名响应，未携带幂等键时正常处理
+func (m *Server) idempotent(ctx *transport.Context, authorized *authorities.Authorized) (bool, error) {
+	key := ctx.GetIdempotencyKey()
+	if key == "" || !m.idempotents[ctx.Request().Method] {
+		return false, nil
+	}
+	account := ""
+	if authorized != nil {
+		account = authorized.ID
+	}
+	return m.httpTransport.Idempotent(ctx, strings.Join([]string{ctx.GetClientID(), account, key}, ":"))
+}
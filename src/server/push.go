@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/36625090/involution/logical"
+	"github.com/go-various/redisplus"
+	"github.com/hashicorp/go-hclog"
+	"gopkg.in/redis.v5"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PushBrokerMemory = "memory"
+	PushBrokerRedis  = "redis"
+)
+
+var _ logical.Pusher = (*pushHub)(nil)
+
+var errPushDisabled = errors.New("websocket is disabled")
+
+//pushHub 管理本实例的WebSocket连接，按账户ID推送消息
+//broker为redis时消息发布到频道，由所有实例(包括本实例)订阅后投递给本地连接
+//未开启WebSocket时为nil，Push返回errPushDisabled
+type pushHub struct {
+	sync.RWMutex
+	logger  hclog.Logger
+	conns   map[string]map[*wsConn]struct{}
+	redis   redisplus.RedisCli
+	channel string
+	pubsub  *redis.PubSub
+	closed  chan struct{}
+}
+
+func newPushHub(broker string, config *redisplus.Config, app string, logger hclog.Logger) (*pushHub, error) {
+	hub := &pushHub{
+		logger: logger.Named("push"),
+		conns:  map[string]map[*wsConn]struct{}{},
+		closed: make(chan struct{}),
+	}
+	if broker != PushBrokerRedis {
+		return hub, nil
+	}
+	if nil == config {
+		return nil, errors.New("redis config is required by push broker")
+	}
+	cli, err := redisplus.NewRedisCli(config, app)
+	if err != nil {
+		return nil, err
+	}
+	hub.redis = cli
+	hub.channel = strings.Join([]string{cli.KeyPrefix(), "push"}, redisplus.RedisKeySep)
+	return hub, nil
+}
+
+//Push 推送消息到账户id的所有连接
+func (h *pushHub) Push(ctx context.Context, id string, event string, data interface{}) error {
+	if h == nil {
+		return errPushDisabled
+	}
+	if id == "" {
+		return errors.New("push account id is empty")
+	}
+	msg := &logical.PushMessage{ID: id, Event: event, Timestamp: time.Now().UnixMilli()}
+	if data != nil {
+		bs, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		msg.Data = bs
+	}
+
+	if h.redis == nil {
+		h.deliver(msg)
+		return nil
+	}
+	bs, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	publisher, ok := h.redis.NativeCmd().(redisPublisher)
+	if !ok {
+		return errors.New("redis client does not support publish")
+	}
+	return publisher.Publish(h.channel, string(bs)).Err()
+}
+
+type redisPublisher interface {
+	Publish(channel, message string) *redis.IntCmd
+}
+
+//subscribe 订阅redis推送频道，broker为memory时无需订阅
+func (h *pushHub) subscribe() error {
+	if h.redis == nil {
+		return nil
+	}
+	pubsub, err := h.redis.Subscribe(h.channel)
+	if err != nil {
+		return err
+	}
+	h.pubsub = pubsub
+	go func() {
+		for {
+			message, err := pubsub.ReceiveMessage()
+			if err != nil {
+				select {
+				case <-h.closed:
+					return
+				default:
+				}
+				h.logger.Error("receive push message", "channel", h.channel, "err", err)
+				time.Sleep(time.Second)
+				continue
+			}
+			var msg logical.PushMessage
+			if err := json.Unmarshal([]byte(message.Payload), &msg); err != nil {
+				h.logger.Error("decode push message", "err", err)
+				continue
+			}
+			h.deliver(&msg)
+		}
+	}()
+	return nil
+}
+
+func (h *pushHub) deliver(msg *logical.PushMessage) {
+	h.RLock()
+	defer h.RUnlock()
+	for conn := range h.conns[msg.ID] {
+		conn.push(msg)
+	}
+}
+
+func (h *pushHub) register(id string, conn *wsConn) {
+	h.Lock()
+	defer h.Unlock()
+	if _, ok := h.conns[id]; !ok {
+		h.conns[id] = map[*wsConn]struct{}{}
+	}
+	h.conns[id][conn] = struct{}{}
+}
+
+func (h *pushHub) unregister(id string, conn *wsConn) {
+	h.Lock()
+	defer h.Unlock()
+	delete(h.conns[id], conn)
+	if len(h.conns[id]) == 0 {
+		delete(h.conns, id)
+	}
+}
+
+func (h *pushHub) close() {
+	if h == nil {
+		return
+	}
+	select {
+	case <-h.closed:
+		return
+	default:
+	}
+	close(h.closed)
+	if h.pubsub != nil {
+		h.pubsub.Close()
+	}
+}
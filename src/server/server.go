@@ -7,10 +7,12 @@ import (
 	"github.com/36625090/involution/config"
 	"github.com/36625090/involution/logical"
 	"github.com/36625090/involution/option"
+	"github.com/36625090/involution/tracing"
 	"github.com/36625090/involution/transport"
 	"github.com/gin-gonic/gin"
 	"github.com/go-various/consul"
 	"github.com/hashicorp/go-hclog"
+	"google.golang.org/grpc"
 	"log"
 	"net"
 	"net/http"
@@ -29,13 +31,20 @@ type Server struct {
 	authorization authorities.Authorization
 	httpTransport *transport.Transport
 	httpServer    *http.Server
+	grpcServer    *grpc.Server
 	netListener   net.Listener
 	connection    *Connection
+	pusher        *pushHub
 	backends      map[string]logical.Backend
+	idempotents   map[string]bool
+	//unsigned 允许JSON-RPC和RESTful路由未签名调用的操作
+	unsigned      map[string]bool
 	service       *consul.Service
 	consulClient  consul.Client
 	globalConfig  *config.GlobalConfig
 	signalChan    chan os.Signal
+	//otelShutdown 停止时导出剩余的span
+	otelShutdown func(context.Context) error
 }
 
 func (m *Server) AddLoggerSinks(sinks ...hclog.SinkAdapter) {
@@ -44,7 +53,7 @@ func (m *Server) AddLoggerSinks(sinks ...hclog.SinkAdapter) {
 	}
 }
 
-func NewServer(opts *option.Options, cfg *config.GlobalConfig, cl consul.Client, logger hclog.InterceptLogger) *Server {
+func NewServer(opts *option.Options, cfg *config.GlobalConfig, cl consul.Client, logger hclog.InterceptLogger) (*Server, error) {
 
 	gin.SetMode(gin.ReleaseMode)
 	en := gin.New()
@@ -52,6 +61,48 @@ func NewServer(opts *option.Options, cfg *config.GlobalConfig, cl consul.Client,
 		en.Use(Cors())
 	}
 
+	httpTransport, err := transport.NewTransport(en, cfg.Transport, logger)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Transport != nil && cfg.Transport.NonceStore == transport.NonceStoreRedis {
+		store, err := transport.NewRedisNonceStore(cfg.RedisConfig)
+		if err != nil {
+			return nil, err
+		}
+		httpTransport.SetNonceStore(store)
+	}
+	if cfg.Transport != nil && cfg.Transport.Idempotency != nil {
+		store, err := newIdempotencyStore(cfg.Transport.Idempotency.Store, cfg)
+		if err != nil {
+			return nil, err
+		}
+		httpTransport.SetIdempotency(cfg.Transport.Idempotency, store)
+	}
+
+	//未开启WebSocket时不创建推送，也不依赖推送的redis配置
+	var pusher *pushHub
+	if opts.Http.WebSocket {
+		if pusher, err = newPushHub(opts.Http.WebSocketPush, cfg.RedisConfig, opts.App, logger); err != nil {
+			return nil, err
+		}
+	}
+
+	var otelShutdown func(context.Context) error
+	if opts.UseOtel {
+		otelShutdown, err = tracing.Setup(context.Background(), &tracing.Settings{
+			ServiceName: opts.App,
+			Exporter:    opts.Otel.Exporter,
+			Endpoint:    opts.Otel.Endpoint,
+			Insecure:    opts.Otel.Insecure,
+			File:        opts.Otel.File,
+			SampleRatio: opts.Otel.SampleRatio,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &Server{
 		ctx:           context.Background(),
 		globalConfig:  cfg,
@@ -59,9 +110,13 @@ func NewServer(opts *option.Options, cfg *config.GlobalConfig, cl consul.Client,
 		consulClient:  cl,
 		logger:        logger,
 		connection:    &Connection{},
+		pusher:        pusher,
 		backends:      map[string]logical.Backend{},
-		httpTransport: transport.NewTransport(en, cfg.Transport, logger),
-	}
+		idempotents:   map[string]bool{},
+		unsigned:      map[string]bool{},
+		httpTransport: httpTransport,
+		otelShutdown:  otelShutdown,
+	}, nil
 }
 
 func (m *Server) Initialize() error {
@@ -72,6 +127,24 @@ func (m *Server) Initialize() error {
 
 	m.initBackendAPIServer()
 
+	if err := m.initRESTRoutes(); err != nil {
+		return err
+	}
+
+	if m.opts.Http.WebSocket {
+		if err := m.initWebSocket(); err != nil {
+			return err
+		}
+	}
+
+	if m.opts.Http.JSONRPC {
+		m.initJSONRPC()
+	}
+
+	if m.opts.UseGrpc {
+		m.initGrpcServer()
+	}
+
 	if m.opts.Ui {
 		m.addDocumentSchema()
 		m.addDocumentUI()
@@ -86,6 +159,11 @@ func (m *Server) RegisterAuthorization(authorization authorities.Authorization)
 	return nil
 }
 
+//Pusher 返回WebSocket推送接口，未开启WebSocket时推送返回错误
+func (m *Server) Pusher() logical.Pusher {
+	return m.pusher
+}
+
 //Start the Server
 //启动服务
 func (m *Server) Start() error {
@@ -99,7 +177,7 @@ func (m *Server) Start() error {
 	m.netListener = l
 	m.httpServer = &http.Server{
 		Addr:         addr,
-		Handler:      m.httpTransport,
+		Handler:      m.streamDeadline(m.httpTransport),
 		IdleTimeout:  time.Second * time.Duration(m.opts.Http.IdleTimeout),
 		ReadTimeout:  time.Second * time.Duration(m.opts.Http.ReadTimeout),
 		WriteTimeout: time.Second * time.Duration(m.opts.Http.WriteTimeout),
@@ -113,6 +191,9 @@ func (m *Server) Start() error {
 			return
 		}
 	}()
+	if err := m.startGrpcServer(); err != nil {
+		return err
+	}
 	m.listenHealthyEndpoint()
 	if err := m.registerService(m.opts.Profile); err != nil {
 		return err
@@ -129,6 +210,9 @@ func (m *Server) Start() error {
 
 	m.unRegisterService()
 
+	if nil != m.grpcServer {
+		m.grpcServer.GracefulStop()
+	}
 	if err := m.httpServer.Shutdown(ctx); err != nil {
 		log.Fatal("server shutdown:", err)
 		return err
@@ -139,6 +223,19 @@ func (m *Server) Start() error {
 	return nil
 }
 
+//streamDeadline 流式请求使用StreamTimeout作为写超时，避免长时间运行的操作被WriteTimeout中断
+func (m *Server) streamDeadline(handler http.Handler) http.Handler {
+	timeout := time.Second * time.Duration(m.opts.Http.StreamTimeout)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if transport.IsStreamRequest(r.Header.Get("Accept")) {
+			if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+				m.logger.Warn("extend stream write deadline", "err", err)
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
 func (m *Server) Stop() {
 	if nil == m.signalChan {
 		return
@@ -147,6 +244,7 @@ func (m *Server) Stop() {
 }
 
 func (m *Server) Cleanup() {
+	m.pusher.close()
 	if nil != m.httpServer {
 		m.httpServer.Close()
 	}
@@ -156,4 +254,11 @@ func (m *Server) Cleanup() {
 	for _, backend := range m.backends {
 		backend.Cleanup(context.Background())
 	}
+	if m.otelShutdown != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		if err := m.otelShutdown(ctx); err != nil {
+			m.logger.Error("shutdown tracing", "err", err)
+		}
+	}
 }
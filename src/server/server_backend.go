@@ -7,6 +7,7 @@ import (
 	"github.com/36625090/involution/authorities"
 	"github.com/36625090/involution/logical"
 	"github.com/36625090/involution/logical/codes"
+	"github.com/36625090/involution/tracing"
 	"github.com/36625090/involution/transport"
 	"github.com/36625090/involution/utils"
 	"strings"
@@ -31,10 +32,42 @@ func (m *Server) RegisterBackend(bkName string, factory logical.Factory, cfg *lo
 	}
 
 	m.backends[bkName] = backend
+	if err := m.registerOperations(bkName, backend); err != nil {
+		return err
+	}
 	m.logger.Info("register backend", "name", bkName)
 	return nil
 }
 
+//registerOperations 记录后端声明为幂等和允许未签名调用的操作
+//多版本操作任一版本声明幂等即生效，所有版本声明允许未签名时才生效
+func (m *Server) registerOperations(name string, backend logical.Backend) error {
+	reply, err := backend.Documents(context.Background())
+	if err != nil {
+		return err
+	}
+	if reply == nil {
+		return nil
+	}
+	for _, document := range reply.Documents {
+		for operation, properties := range document.Operations {
+			idempotent, unsigned := properties.Idempotent, properties.Unsigned
+			for _, version := range properties.Versions {
+				idempotent = idempotent || version.Idempotent
+				unsigned = unsigned && version.Unsigned
+			}
+			method := strings.Join([]string{name, document.Endpoint, operation}, ".")
+			if idempotent {
+				m.idempotents[method] = true
+			}
+			if unsigned {
+				m.unsigned[method] = true
+			}
+		}
+	}
+	return nil
+}
+
 func (m *Server) initBackendAPIServer() {
 
 	path := strings.Join([]string{m.opts.Http.Path, "api"}, "/")
@@ -42,53 +75,73 @@ func (m *Server) initBackendAPIServer() {
 		m.httpTransport.Use(m.loggerTracker(path))
 	}
 
-	m.httpTransport.AddHandle(path, logical.HttpMethodPOST, func(ctx *transport.Context) (err error) {
-		request := ctx.Request()
-		m.connection.Inc()
-		defer func() {
-			m.connection.Dec()
-			if err != nil {
-				m.connection.Error()
-			}
-		}()
-
-		backend, ok := m.backends[request.Backend()]
-
-		if !ok {
-			ctx.WithCode(codes.CodeBackendIssue).WithMessage("invalid backend")
-			return errors.New("invalid backend")
-		}
+	m.httpTransport.AddHandle(path, logical.HttpMethodPOST, m.handleAPIRequest)
+}
 
-		authorized, err := m.preAuthorization(request.Method, ctx.GetAuthToken())
+//handleAPIRequest HTTP和gRPC共用的请求处理：鉴权后分发到后端逻辑
+func (m *Server) handleAPIRequest(ctx *transport.Context) (err error) {
+	request := ctx.Request()
+	m.connection.Inc()
+	defer func() {
+		m.connection.Dec()
 		if err != nil {
-			ctx.WithCode(codes.CodeUnauthorized).WithError(err)
-			return err
+			m.connection.Error()
 		}
+	}()
 
-		args, err := ctx.DecodeArgs()
-		if err != nil {
-			ctx.WithCode(codes.CodeFailedDecodeArgs).WithError(err)
-			return err
-		}
+	authorized, err := m.preAuthorization(ctx.TraceContext(), request.Method, ctx.GetAuthToken())
+	if err != nil {
+		ctx.WithCode(codes.CodeUnauthorized).WithError(err)
+		return err
+	}
 
-		args.Authorized = authorized
-		resp, werr := backend.HandleRequest(context.Background(), args)
-		if werr != nil {
-			ctx.WithCode(werr.Code).WithMessage(werr.String())
-			return werr.Error()
-		}
-		if resp.Code != 0 {
-			ctx.WithCode(codes.ReturnCode(resp.Code)).WithMessage(resp.Message)
-			return nil
-		}
-		ctx.WithContent(resp.Data)
-		ctx.WithPagination(resp.Pagination)
+	return m.handleBackendRequest(ctx, authorized)
+}
+
+//handleBackendRequest 将已鉴权的请求分发到后端逻辑
+func (m *Server) handleBackendRequest(ctx *transport.Context, authorized *authorities.Authorized) error {
+	request := ctx.Request()
+	backend, ok := m.backends[request.Backend()]
+	if !ok {
+		ctx.WithCode(codes.CodeBackendIssue).WithMessage("invalid backend")
+		return errors.New("invalid backend")
+	}
+
+	if reused, err := m.idempotent(ctx, authorized); err != nil {
+		ctx.WithCode(codes.CodeIdempotencyConflict).WithError(err)
+		return err
+	} else if reused {
 		return nil
-	})
+	}
+
+	args, err := ctx.DecodeArgs()
+	if err != nil {
+		ctx.WithCode(codes.CodeFailedDecodeArgs).WithError(err)
+		return err
+	}
+
+	args.Authorized = authorized
+	resp, werr := backend.HandleRequest(tracing.Detach(ctx.TraceContext()), args)
+	if werr != nil {
+		ctx.WithCode(werr.Code).WithMessage(werr.String())
+		return werr.Error()
+	}
+	if resp.Code != 0 {
+		ctx.WithCode(codes.ReturnCode(resp.Code)).WithMessage(resp.Message)
+		return nil
+	}
+	ctx.WithContent(resp.Data)
+	ctx.WithPagination(resp.Pagination)
+	return nil
 
 }
 
-func (m *Server) preAuthorization(method string, token string) (*authorities.Authorized, error) {
+func (m *Server) preAuthorization(ctx context.Context, method string, token string) (authorized *authorities.Authorized, err error) {
+	ctx, span := tracing.Start(ctx, "preAuthorization")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	if nil == m.authorization {
 		return nil, errors.New("authorization unavailable")
 	}
@@ -105,5 +158,5 @@ func (m *Server) preAuthorization(method string, token string) (*authorities.Aut
 		return nil, errors.New("invalid token")
 	}
 
-	return m.authorization.Authentication(context.TODO(), token)
+	return m.authorization.Authentication(ctx, token)
 }
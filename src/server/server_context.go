@@ -3,8 +3,11 @@ package server
 import (
 	"fmt"
 	"github.com/36625090/involution/logical"
+	"github.com/36625090/involution/tracing"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"net/http"
 	"time"
 )
 
@@ -24,11 +27,21 @@ func (m *Server) requestTracer() gin.HandlerFunc {
 		if c.Request.Method == "OPTIONS" {
 			return
 		}
-		// 设置 trace-id 变量
+		// 设置 trace-id 变量，保留上游的X-Trace-ID和traceparent，响应头返回本服务的链路信息
 		c.Request.Header.Set(string(logical.HeaderApplicationKey), m.opts.App)
-		c.Request.Header.Set(string(logical.HeaderTraceIDKey), uuid.New().String())
+		//开启OpenTelemetry时以服务端span的trace-id、span-id作为本服务的traceparent
+		trace := logical.ResolveTrace(c.Request.Header)
+		ctx, span := tracing.StartServer(c.Request.Context(), c.Request.Header, trace, c.Request.Method+" "+c.FullPath())
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+		trace.Inject(c.Request.Header)
+		trace.Inject(c.Writer.Header())
 
 		c.Next()
+		span.SetAttributes(attribute.Int("http.status_code", c.Writer.Status()))
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			span.SetStatus(otelcodes.Error, http.StatusText(c.Writer.Status()))
+		}
 	}
 }
 
@@ -46,10 +59,11 @@ func (m *Server) loggerTracker(path string) gin.HandlerFunc {
 			if c.Request.RequestURI != path{
 				return
 			}
-			msg := fmt.Sprintf("client=%v client-id=%s trace-id=%s application=%s uri=%v code=%d latency=%v",
+			msg := fmt.Sprintf("client=%v client-id=%s trace-id=%s traceparent=%s application=%s uri=%v code=%d latency=%v",
 				c.Request.RemoteAddr,
 				c.Request.Header.Get(logical.HeaderClientIDKey.String()),
 				c.Request.Header.Get(logical.HeaderTraceIDKey.String()),
+				c.Request.Header.Get(logical.HeaderTraceparentKey.String()),
 				c.Request.Header.Get(logical.HeaderApplicationKey.String()),
 				c.Request.RequestURI, c.Writer.Status(), latency)
 			m.logger.Info(msg)
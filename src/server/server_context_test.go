@@ -0,0 +1,42 @@
+package server
+
+import (
+	"github.com/36625090/involution/logical"
+	"github.com/36625090/involution/option"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer_RequestTracer(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	m := &Server{opts: &option.Options{App: "test"}}
+	engine := gin.New()
+	engine.Use(m.requestTracer())
+	var args *logical.Args
+	engine.GET("/", func(c *gin.Context) {
+		args = &logical.Args{Headers: map[string][]string{}}
+		args.SetTraceHeaders(c.Request.Header)
+	})
+
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(logical.HeaderTraceIDKey.String(), "gateway-123")
+	req.Header.Set(logical.HeaderTraceparentKey.String(), parent)
+	req.Header.Set(logical.HeaderTracestateKey.String(), "vendor=value")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	traceparent := w.Header().Get(logical.HeaderTraceparentKey.String())
+	outbound := args.TraceHeaders()
+	if w.Header().Get(logical.HeaderTraceIDKey.String()) != "gateway-123" ||
+		w.Header().Get(logical.HeaderTracestateKey.String()) != "vendor=value" ||
+		traceparent == parent || traceparent[:35] != parent[:35] {
+		t.Fatal("unexpected response headers", w.Header())
+	}
+	if outbound[logical.HeaderTraceIDKey.String()] != "gateway-123" || outbound[logical.HeaderTraceparentKey.String()] != traceparent ||
+		outbound[logical.HeaderTracestateKey.String()] != "vendor=value" {
+		t.Fatal("unexpected outbound headers", outbound)
+	}
+}
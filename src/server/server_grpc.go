@@ -0,0 +1,32 @@
+package server
+
+import (
+	"fmt"
+	"google.golang.org/grpc"
+	"net"
+)
+
+//initGrpcServer 初始化gRPC服务，与HTTP接口共用鉴权、签名策略和后端逻辑
+func (m *Server) initGrpcServer() {
+	m.grpcServer = grpc.NewServer()
+	m.httpTransport.RegisterGRPC(m.grpcServer, m.handleAPIRequest)
+}
+
+//startGrpcServer 启动gRPC监听
+func (m *Server) startGrpcServer() error {
+	if nil == m.grpcServer {
+		return nil
+	}
+	addr := fmt.Sprintf("%s:%d", m.opts.Grpc.Address, m.opts.Grpc.Port)
+	m.logger.Info("grpc server listening on ", "address", addr)
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := m.grpcServer.Serve(l); err != nil && err != grpc.ErrServerStopped {
+			m.logger.Error("start grpc server", "err", err)
+		}
+	}()
+	return nil
+}
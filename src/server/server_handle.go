@@ -26,3 +26,8 @@ func (m *Server) AddHandle(path string, method logical.HttpMethod, handle func(*
 		return handle(c, params)
 	})
 }
+
+//AddMiddleware 添加请求信封中间件，参考transport.Middleware
+func (m *Server) AddMiddleware(middlewares ...transport.Middleware) {
+	m.httpTransport.AddMiddleware(middlewares...)
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/36625090/involution/logical/codes"
+	"github.com/36625090/involution/transport"
+	"github.com/36625090/involution/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+const jsonrpcVersion = "2.0"
+
+//JSON-RPC 2.0 预定义错误码
+const (
+	JSONRPCParseError     = -32700
+	JSONRPCInvalidRequest = -32600
+	JSONRPCMethodNotFound = -32601
+	JSONRPCInvalidParams  = -32602
+	JSONRPCInternalError  = -32603
+)
+
+const (
+	defaultJSONRPCBatchSize        = 20
+	defaultJSONRPCBatchConcurrency = 4
+)
+
+//jsonrpcRequest 调用对象，扩展成员timestamp nonce version sign sign_type与请求信封的同名字段一致
+type jsonrpcRequest struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	//ID 不存在时为通知，不返回响应；null会保留为"null"
+	ID json.RawMessage `json:"id"`
+
+	Timestamp  int64  `json:"timestamp"`
+	Nonce      string `json:"nonce"`
+	APIVersion string `json:"version"`
+	Sign       string `json:"sign"`
+	SignType   string `json:"sign_type"`
+}
+
+//jsonrpcResponse 响应对象，响应已签名时扩展成员timestamp trace_id sign为签名响应的同名字段
+type jsonrpcResponse struct {
+	Version   string
+	Result    interface{}
+	Error     *JSONRPCError
+	ID        json.RawMessage
+	Timestamp int64
+	TraceID   string
+	Sign      string
+}
+
+//MarshalJSON 成功时只包含result，失败时只包含error
+func (r *jsonrpcResponse) MarshalJSON() ([]byte, error) {
+	if r.Error != nil {
+		return json.Marshal(&struct {
+			Version   string          `json:"jsonrpc"`
+			Error     *JSONRPCError   `json:"error"`
+			ID        json.RawMessage `json:"id"`
+			Timestamp int64           `json:"timestamp,omitempty"`
+			TraceID   string          `json:"trace_id,omitempty"`
+			Sign      string          `json:"sign,omitempty"`
+		}{r.Version, r.Error, r.ID, r.Timestamp, r.TraceID, r.Sign})
+	}
+	return json.Marshal(&struct {
+		Version   string          `json:"jsonrpc"`
+		Result    interface{}     `json:"result"`
+		ID        json.RawMessage `json:"id"`
+		Timestamp int64           `json:"timestamp,omitempty"`
+		TraceID   string          `json:"trace_id,omitempty"`
+		Sign      string          `json:"sign,omitempty"`
+	}{r.Version, r.Result, r.ID, r.Timestamp, r.TraceID, r.Sign})
+}
+
+//JSONRPCError JSON-RPC错误对象，data.code为原始的codes.ReturnCode
+type JSONRPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+type jsonrpcErrorData struct {
+	Code int `json:"code"`
+}
+
+//jsonrpcPagination 带分页信息的结果
+type jsonrpcPagination struct {
+	Data       interface{} `json:"data"`
+	Pagination interface{} `json:"pagination"`
+}
+
+//NewJSONRPCError 将codes.ReturnCode映射为JSON-RPC错误码
+//路由类错误映射为Method not found，参数类错误映射为Invalid params，服务端错误映射为Internal error，业务错误码原样使用
+func NewJSONRPCError(code codes.ReturnCode, message string) *JSONRPCError {
+	rpcCode := code.Int()
+	switch code {
+	case codes.CodeEndpointNotFound, codes.CodeOperationNotFound, codes.CodeBackendIssue, codes.CodeUnsupportedVersion:
+		rpcCode = JSONRPCMethodNotFound
+	case codes.CodeDataValidateException, codes.CodeInvalidRequestParameter,
+		codes.CodeBindRequestData, codes.CodeFailedDecodeArgs:
+		rpcCode = JSONRPCInvalidParams
+	case codes.CodeServerInternalError, codes.CodeOperationHandlerIssue, codes.CodeHandleRequest:
+		rpcCode = JSONRPCInternalError
+	}
+	return &JSONRPCError{
+		Code:    rpcCode,
+		Message: message,
+		Data:    &jsonrpcErrorData{Code: code.Int()},
+	}
+}
+
+//initJSONRPC 注册JSON-RPC 2.0接口
+//method为backend.endpoint.operation，params为Args.Data，令牌使用Authorization请求头
+//每个调用按method=method data=params的请求信封签名，与请求信封同样经过验签、防重放、中间件、幂等及响应签名；
+//后端声明允许未签名调用的操作可以不签名，参考framework.EndpointOperation.Unsigned
+//签名响应的content为result(分页时为result.data)，pagination为result.pagination，code为0，message为空；
+//业务错误的响应同样签名，code为error.data.code，message为error.message
+//批量调用不使用请求头中的幂等键，不支持加密、上传和下载
+func (m *Server) initJSONRPC() {
+	path := strings.Join([]string{m.opts.Http.Path, "jsonrpc"}, "/")
+	m.logger.Info("initialize handle", "path", path, "method", http.MethodPost)
+	m.httpTransport.POST(path, m.serveJSONRPC)
+}
+
+func (m *Server) serveJSONRPC(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusOK, jsonrpcFailure(nil, JSONRPCParseError, err.Error()))
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		var req jsonrpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			c.JSON(http.StatusOK, jsonrpcFailure(nil, JSONRPCParseError, err.Error()))
+			return
+		}
+		resp := m.callJSONRPC(c, &req, false)
+		if resp == nil {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	var requests []json.RawMessage
+	if err := json.Unmarshal(body, &requests); err != nil {
+		c.JSON(http.StatusOK, jsonrpcFailure(nil, JSONRPCParseError, err.Error()))
+		return
+	}
+	size, concurrency := m.jsonrpcBatchLimits()
+	if len(requests) == 0 || len(requests) > size {
+		c.JSON(http.StatusOK, jsonrpcFailure(nil, JSONRPCInvalidRequest,
+			fmt.Sprintf("batch size must be between 1 and %d", size)))
+		return
+	}
+
+	responses := make([]*jsonrpcResponse, len(requests))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, raw := range requests {
+		var req jsonrpcRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			responses[i] = jsonrpcFailure(nil, JSONRPCInvalidRequest, err.Error())
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, req *jsonrpcRequest) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			responses[i] = m.callJSONRPC(c, req, true)
+		}(i, &req)
+	}
+	wg.Wait()
+
+	//通知不返回响应，全部为通知时不返回内容
+	result := make([]*jsonrpcResponse, 0, len(responses))
+	for _, resp := range responses {
+		if resp != nil {
+			result = append(result, resp)
+		}
+	}
+	if len(result) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (m *Server) jsonrpcBatchLimits() (int, int) {
+	size, concurrency := defaultJSONRPCBatchSize, defaultJSONRPCBatchConcurrency
+	if settings := m.globalConfig.Transport; settings != nil {
+		if settings.BatchSize > 0 {
+			size = settings.BatchSize
+		}
+		if settings.BatchConcurrency > 0 {
+			concurrency = settings.BatchConcurrency
+		}
+	}
+	return size, concurrency
+}
+
+//callJSONRPC 处理单个调用，通知返回nil，shared为true时请求头由批量调用共用
+func (m *Server) callJSONRPC(c *gin.Context, req *jsonrpcRequest, shared bool) (resp *jsonrpcResponse) {
+	notification := req.ID == nil
+	defer func() {
+		if r := recover(); r != nil {
+			m.logger.Error("received panic", "err", r, "stack", string(debug.Stack()))
+			resp = jsonrpcFailure(req.ID, JSONRPCInternalError, fmt.Sprintf("%v", r))
+		}
+		if notification {
+			resp = nil
+		}
+	}()
+
+	if req.Version != jsonrpcVersion || req.Method == "" {
+		return jsonrpcFailure(req.ID, JSONRPCInvalidRequest, "invalid JSON-RPC 2.0 request")
+	}
+
+	methods := strings.Split(req.Method, ".")
+	if len(methods) != 3 {
+		return jsonrpcFailure(req.ID, JSONRPCMethodNotFound, "method not supported: "+req.Method)
+	}
+
+	params := req.Params
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		params = json.RawMessage("{}")
+	}
+	request := &transport.Request{
+		Method:    req.Method,
+		Data:      transport.Data(params),
+		Timestamp: req.Timestamp,
+		Nonce:     req.Nonce,
+		Version:   req.APIVersion,
+		Sign:      req.Sign,
+		SignType:  req.SignType,
+	}
+	opts := &transport.ServeOptions{Shared: shared, Unsigned: m.unsigned[req.Method] && req.Sign == ""}
+	response := m.httpTransport.ServeRequest(c, request, opts, m.handleAPIRequest)
+
+	resp = &jsonrpcResponse{Version: jsonrpcVersion, ID: req.ID}
+	if response.Sign != "" {
+		resp.Timestamp, resp.TraceID, resp.Sign = response.Timestamp, response.TraceID, response.Sign
+	}
+	if response.Code != codes.CodeSuccess.Int() {
+		resp.Error = NewJSONRPCError(codes.ReturnCode(response.Code), response.Message)
+		return resp
+	}
+	resp.Result = response.Content
+	if !utils.IsNil(response.Pagination) {
+		resp.Result = &jsonrpcPagination{Data: response.Content, Pagination: response.Pagination}
+	}
+	return resp
+}
+
+func jsonrpcFailure(id json.RawMessage, code int, message string) *jsonrpcResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &jsonrpcResponse{
+		Version: jsonrpcVersion,
+		Error:   &JSONRPCError{Code: code, Message: message},
+		ID:      id,
+	}
+}
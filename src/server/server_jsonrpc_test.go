@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/36625090/involution/logical"
+	"github.com/36625090/involution/logical/codes"
+	"github.com/36625090/involution/transport"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServer_JSONRPC(t *testing.T) {
+	m := newTestWebSocketServer(t)
+	m.initJSONRPC()
+
+	call := func(body string) (int, string) {
+		req := httptest.NewRequest(http.MethodPost, "/jsonrpc", strings.NewReader(body))
+		req.Header.Set("Authorization", "token-1")
+		req.Header.Set("User-Agent", "test")
+		w := httptest.NewRecorder()
+		m.httpTransport.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	if _, body := call(`{"jsonrpc":"2.0","method":"account.user.home","params":{"a":1},"id":1}`); body != `{"jsonrpc":"2.0","result":"1:home","id":1}` {
+		t.Fatal("unexpected result", body)
+	}
+	if _, body := call(`{"jsonrpc":"2.0","method":"account.user.home","id":null}`); body != `{"jsonrpc":"2.0","result":"1:home","id":null}` {
+		t.Fatal("unexpected result for null id", body)
+	}
+	if status, body := call(`{"jsonrpc":"2.0","method":"account.user.home"}`); status != http.StatusNoContent || body != "" {
+		t.Fatal("notification responded", status, body)
+	}
+	if _, body := call(`{bad`); !strings.Contains(body, `"code":-32700`) {
+		t.Fatal("unexpected parse error", body)
+	}
+
+	_, body := call(`[
+		{"jsonrpc":"2.0","method":"account.user.home","id":"a"},
+		{"jsonrpc":"2.0","method":"account.user.home"},
+		{"jsonrpc":"2.0","method":"account.user.missing","id":"b"},
+		{"jsonrpc":"2.0","method":"account.home","id":"c"},
+		{"jsonrpc":"1.0","method":"account.user.home","id":"d"}
+	]`)
+	var responses []struct {
+		Result interface{}   `json:"result"`
+		Error  *JSONRPCError `json:"error"`
+		ID     string        `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(body), &responses); err != nil {
+		t.Fatal(err, body)
+	}
+	if len(responses) != 4 {
+		t.Fatal("unexpected batch responses", body)
+	}
+	if responses[0].ID != "a" || responses[0].Result != "1:home" {
+		t.Fatal("unexpected batch result", body)
+	}
+	if responses[1].ID != "b" || responses[1].Error.Code != JSONRPCMethodNotFound ||
+		responses[1].Error.Data.(map[string]interface{})["code"] != float64(1005) {
+		t.Fatal("unexpected operation error", body)
+	}
+	if responses[2].Error.Code != JSONRPCMethodNotFound || responses[3].Error.Code != JSONRPCInvalidRequest {
+		t.Fatal("unexpected request errors", body)
+	}
+}
+
+type countingBackend struct {
+	logical.Backend
+	calls *int32
+}
+
+func (b countingBackend) HandleRequest(ctx context.Context, args *logical.Args) (*logical.Reply, *logical.WrapperError) {
+	atomic.AddInt32(b.calls, 1)
+	return b.Backend.HandleRequest(ctx, args)
+}
+
+//testSignedServer 要求客户端client使用md5签名的服务，开启幂等请求并统计后端调用和中间件执行次数
+type testSignedServer struct {
+	*Server
+	signer      transport.Signer
+	calls       int32
+	middlewares int32
+}
+
+func newTestSignedServer(t *testing.T, backend logical.Backend) *testSignedServer {
+	settings := &transport.Settings{
+		SignType: transport.SignTypeMD5,
+		SignKeys: map[string]string{"client": "secret", transport.GlobalSignKey: "global"},
+	}
+	s := &testSignedServer{Server: newTestWebSocketServer(t)}
+	tr, err := transport.NewTransport(gin.New(), settings, s.logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.SetIdempotency(&transport.IdempotencySettings{}, nil)
+	tr.AddMiddleware(func(next transport.Handle) transport.Handle {
+		return func(c *transport.Context) error {
+			atomic.AddInt32(&s.middlewares, 1)
+			return next(c)
+		}
+	})
+	signers, err := transport.NewSigners(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.signer = signers[transport.SignTypeMD5]
+	s.httpTransport = tr
+	s.backends["account"] = countingBackend{Backend: backend, calls: &s.calls}
+	s.idempotents = map[string]bool{}
+	s.unsigned = map[string]bool{}
+	return s
+}
+
+func TestServer_JSONRPCSigned(t *testing.T) {
+	m := newTestSignedServer(t, testBackend{})
+	m.idempotents["account.user.home"] = true
+	m.unsigned["account.user.public"] = true
+	m.initJSONRPC()
+	tr, signer := m.httpTransport, m.signer
+
+	var err error
+	call := func(method string, sign bool, idempotencyKey string) *jsonrpcResponse {
+		req := &transport.Request{Method: method, Data: transport.Data(`{"a":1}`), Timestamp: time.Now().UnixMilli(),
+			Version: "1.0", SignType: transport.SignTypeMD5}
+		if sign {
+			if req.Sign, err = signer.Sign(transport.KeyID{ID: "client"}, req); err != nil {
+				t.Fatal(err)
+			}
+		}
+		body, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "method": req.Method, "params": req.Data,
+			"id": 1, "timestamp": req.Timestamp, "version": req.Version, "sign": req.Sign, "sign_type": req.SignType})
+		r := httptest.NewRequest(http.MethodPost, "/jsonrpc", bytes.NewReader(body))
+		r.Header.Set("Authorization", "token-1")
+		r.Header.Set(logical.HeaderClientIDKey.String(), "client")
+		r.Header.Set(logical.HeaderIdempotencyKey.String(), idempotencyKey)
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, r)
+		var resp struct {
+			Result    interface{}   `json:"result"`
+			Error     *JSONRPCError `json:"error"`
+			Timestamp int64         `json:"timestamp"`
+			TraceID   string        `json:"trace_id"`
+			Sign      string        `json:"sign"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		return &jsonrpcResponse{Result: resp.Result, Error: resp.Error, Timestamp: resp.Timestamp, TraceID: resp.TraceID, Sign: resp.Sign}
+	}
+
+	if resp := call("account.user.home", false, ""); resp.Error == nil ||
+		resp.Error.Data.(map[string]interface{})["code"] != float64(codes.CodeInvalidSignature) {
+		t.Fatal("unsigned call accepted", resp.Result, resp.Error)
+	}
+	if m.calls != 0 {
+		t.Fatal("backend called for unsigned request")
+	}
+
+	first := call("account.user.home", true, "key-1")
+	if first.Error != nil || first.Result != "1:home" || first.Sign == "" {
+		t.Fatal("unexpected signed result", first.Result, first.Error)
+	}
+	signed := &transport.Response{Content: first.Result, TraceID: first.TraceID, Timestamp: first.Timestamp}
+	if err := signer.Verify(transport.KeyID{ID: transport.GlobalSignKey}, first.Sign, signed); err != nil {
+		t.Fatal("invalid response sign", err)
+	}
+	if second := call("account.user.home", true, "key-1"); second.Sign != first.Sign || m.calls != 1 {
+		t.Fatal("idempotent call was not reused", m.calls)
+	}
+
+	if resp := call("account.user.public", false, ""); resp.Error != nil || resp.Result != "1:public" {
+		t.Fatal("unsigned operation rejected", resp.Error)
+	}
+	if m.middlewares != 3 {
+		t.Fatal("middlewares were not applied", m.middlewares)
+	}
+}
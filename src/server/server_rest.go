@@ -0,0 +1,209 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/36625090/involution/logical"
+	"github.com/36625090/involution/logical/codes"
+	"github.com/36625090/involution/transport"
+	"github.com/gin-gonic/gin"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var restPathParam = regexp.MustCompile(`\{([^/{}]+)\}`)
+
+//restRoute 后端操作声明的RESTful路由
+type restRoute struct {
+	name      string
+	backend   logical.Backend
+	method    string
+	path      string
+	endpoint  string
+	operation string
+	input     []*logical.Field
+}
+
+//initRESTRoutes 注册后端操作声明的RESTful路由，与请求信封接口并存
+//路由路径为 Http.Path + 路径模板，模板参数{name}绑定到同名字段
+//请求按method=backend.endpoint.operation的请求信封签名，签名字段使用请求头X-Timestamp X-Nonce X-Sign X-Sign-Type，
+//data为JSON字符串 "HTTP方法 请求URI(含查询参数)\n原始请求体"，参考restSignData；
+//与请求信封同样经过验签、防重放、中间件、幂等及响应签名，后端声明允许未签名调用的操作可以不签名
+//响应为签名后的响应信封，下载响应的签名信息在响应头；不支持加密和上传
+func (m *Server) initRESTRoutes() (err error) {
+	var routes []*restRoute
+	for name, backend := range m.backends {
+		reply, err := backend.Documents(m.ctx)
+		if err != nil {
+			return err
+		}
+		if reply == nil {
+			continue
+		}
+		for _, document := range reply.Documents {
+			for operation, properties := range document.Operations {
+				if properties.Path == "" {
+					continue
+				}
+				routes = append(routes, &restRoute{
+					name:      name,
+					backend:   backend,
+					method:    properties.Method,
+					path:      properties.Path,
+					endpoint:  document.Endpoint,
+					operation: operation,
+					input:     properties.Input,
+				})
+			}
+		}
+	}
+	//按路径排序保证注册顺序稳定
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].path+routes[i].method < routes[j].path+routes[j].method
+	})
+
+	//gin路由冲突时panic，转换为初始化错误
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("register rest route: %v", r)
+		}
+	}()
+	for _, route := range routes {
+		path := m.opts.Http.Path + restPathParam.ReplaceAllString(route.path, ":$1")
+		m.logger.Info("initialize rest route", "path", path, "method", route.method,
+			"operation", route.methodName())
+		m.httpTransport.Handle(route.method, path, m.restHandler(route))
+	}
+	return nil
+}
+
+func (r *restRoute) methodName() string {
+	return strings.Join([]string{r.name, r.endpoint, r.operation}, ".")
+}
+
+func (m *Server) restHandler(route *restRoute) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := c.GetRawData()
+		code := codes.CodeReadRequestBody
+		var data json.RawMessage
+		if err == nil {
+			code = codes.CodeBindRequestData
+			data, err = bindRESTData(c, body, route.input)
+		}
+
+		timestamp, _ := strconv.ParseInt(c.GetHeader(logical.HeaderTimestampKey.String()), 10, 64)
+		request := &transport.Request{
+			Method:    route.methodName(),
+			Data:      transport.Data(data),
+			Timestamp: timestamp,
+			Nonce:     c.GetHeader(logical.HeaderNonceKey.String()),
+			Sign:      c.GetHeader(logical.HeaderSignKey.String()),
+			SignType:  c.GetHeader(logical.HeaderSignTypeKey.String()),
+		}
+		opts := &transport.ServeOptions{
+			Unsigned: m.unsigned[request.Method] && request.Sign == "",
+			SignData: restSignData(c, body),
+		}
+		//请求体或参数错误在验签之后返回
+		m.httpTransport.HandleRequest(c, request, opts, func(ctx *transport.Context) error {
+			if err != nil {
+				ctx.WithCode(code).WithError(err)
+				return err
+			}
+			return m.handleAPIRequest(ctx)
+		})
+	}
+}
+
+//restSignData RESTful请求参与签名的data
+func restSignData(c *gin.Context, body []byte) transport.Data {
+	bs, _ := json.Marshal(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n" + string(body))
+	return transport.Data(bs)
+}
+
+//bindRESTData 合并请求体、查询参数和路径参数为操作输入，优先级为 路径 > 查询 > 请求体
+//查询和路径参数按输入字段的类型转换，列表字段接收重复的查询参数
+func bindRESTData(c *gin.Context, body []byte, input []*logical.Field) (json.RawMessage, error) {
+	fields := map[string]*logical.Field{}
+	for _, field := range input {
+		fields[strings.Split(field.Field, ",")[0]] = field
+	}
+
+	data := map[string]interface{}{}
+	if c.ContentType() == gin.MIMEPOSTForm {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		if err := bindRESTValues(data, fields, form); err != nil {
+			return nil, fmt.Errorf("form parameter %v", err)
+		}
+	} else if len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &data); err != nil {
+			return nil, fmt.Errorf("request body must be a JSON object: %v", err)
+		}
+	}
+
+	if err := bindRESTValues(data, fields, c.Request.URL.Query()); err != nil {
+		return nil, fmt.Errorf("query parameter %v", err)
+	}
+	params := url.Values{}
+	for _, param := range c.Params {
+		params.Set(param.Key, param.Value)
+	}
+	if err := bindRESTValues(data, fields, params); err != nil {
+		return nil, fmt.Errorf("path parameter %v", err)
+	}
+	return json.Marshal(data)
+}
+
+func bindRESTValues(data map[string]interface{}, fields map[string]*logical.Field, values url.Values) error {
+	for key, value := range values {
+		v, err := restValue(fields[key], value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		data[key] = v
+	}
+	return nil
+}
+
+//restValue 按字段类型转换字符串参数，未声明的字段保持字符串
+func restValue(field *logical.Field, values []string) (interface{}, error) {
+	if field == nil {
+		if len(values) == 1 {
+			return values[0], nil
+		}
+		return values, nil
+	}
+	if field.IsList {
+		list := make([]interface{}, 0, len(values))
+		for _, value := range values {
+			v, err := restScalar(field.Kind, value)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	}
+	return restScalar(field.Kind, values[len(values)-1])
+}
+
+func restScalar(kind string, value string) (interface{}, error) {
+	switch kind {
+	case "bool":
+		return strconv.ParseBool(value)
+	case "int", "int8", "int16", "int32", "int64":
+		return strconv.ParseInt(value, 10, 64)
+	case "uint", "uint8", "uint16", "uint32", "uint64":
+		return strconv.ParseUint(value, 10, 64)
+	case "float32", "float64":
+		return strconv.ParseFloat(value, 64)
+	}
+	return value, nil
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/36625090/involution/logical"
+	"github.com/36625090/involution/logical/codes"
+	"github.com/36625090/involution/transport"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testRESTBackend struct {
+	testBackend
+}
+
+func (testRESTBackend) HandleRequest(ctx context.Context, args *logical.Args) (*logical.Reply, *logical.WrapperError) {
+	data, _ := args.DataBytes()
+	return &logical.Reply{Data: json.RawMessage(data)}, nil
+}
+
+func (testRESTBackend) Documents(context.Context) (*logical.DocumentsReply, error) {
+	return &logical.DocumentsReply{Documents: logical.Documents{{
+		Endpoint: "user",
+		Operations: map[string]*logical.Operation{
+			"get": {Method: "GET", Path: "/account/user/{id}", Input: []*logical.Field{
+				{Field: "id", Kind: "int64"},
+				{Field: "tags,omitempty", Kind: "string", IsList: true},
+			}},
+			"update": {Method: "PUT", Path: "/account/user/{id}", Input: []*logical.Field{
+				{Field: "id", Kind: "int"},
+			}},
+		},
+	}}}, nil
+}
+
+func TestServer_RESTRoutes(t *testing.T) {
+	m := newTestWebSocketServer(t)
+	m.backends["account"] = testRESTBackend{}
+	if err := m.initRESTRoutes(); err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(method, path, body string) *transport.Response {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "token-1")
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		m.httpTransport.ServeHTTP(w, req)
+		var resp transport.Response
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		return &resp
+	}
+
+	content := func(resp *transport.Response) string {
+		bs, _ := json.Marshal(resp.Content)
+		return string(bs)
+	}
+
+	if resp := call(http.MethodGet, "/account/user/12?tags=a&tags=b&q=x", ""); content(resp) != `{"id":12,"q":"x","tags":["a","b"]}` {
+		t.Fatal("unexpected get binding", resp.Code, resp.Message, content(resp))
+	}
+	if resp := call(http.MethodPut, "/account/user/7", `{"id":1,"name":"n"}`); content(resp) != `{"id":7,"name":"n"}` {
+		t.Fatal("unexpected put binding", resp.Code, resp.Message, content(resp))
+	}
+	if resp := call(http.MethodGet, "/account/user/abc", ""); resp.Code == 0 {
+		t.Fatal("invalid path parameter accepted")
+	}
+}
+
+func TestServer_RESTSigned(t *testing.T) {
+	m := newTestSignedServer(t, testRESTBackend{})
+	m.idempotents["account.user.update"] = true
+	if err := m.initRESTRoutes(); err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(method, target, body string, sign bool, idempotencyKey string) *transport.Response {
+		signed := &transport.Request{Method: "account.user." + map[string]string{http.MethodGet: "get", http.MethodPut: "update"}[method],
+			Timestamp: time.Now().UnixMilli(), SignType: transport.SignTypeMD5}
+		signed.Data, _ = transport.NewData(method + " " + target + "\n" + body)
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "token-1")
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(logical.HeaderClientIDKey.String(), "client")
+		req.Header.Set(logical.HeaderIdempotencyKey.String(), idempotencyKey)
+		req.Header.Set(logical.HeaderTimestampKey.String(), strconv.FormatInt(signed.Timestamp, 10))
+		if sign {
+			s, err := m.signer.Sign(transport.KeyID{ID: "client"}, signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set(logical.HeaderSignKey.String(), s)
+		}
+		w := httptest.NewRecorder()
+		m.httpTransport.ServeHTTP(w, req)
+		var resp transport.Response
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		return &resp
+	}
+
+	if resp := call(http.MethodGet, "/account/user/12", "", false, ""); resp.Code != codes.CodeInvalidSignature.Int() || m.calls != 0 {
+		t.Fatal("unsigned request accepted", resp.Code, resp.Message)
+	}
+	if resp := call(http.MethodGet, "/account/user/12?tags=a", "", true, ""); resp.Code != 0 {
+		t.Fatal("signed request rejected", resp.Code, resp.Message)
+	} else if err := m.signer.Verify(transport.KeyID{ID: transport.GlobalSignKey}, resp.Sign, resp); err != nil {
+		t.Fatal("invalid response sign", err)
+	}
+
+	first := call(http.MethodPut, "/account/user/7", `{"name":"n"}`, true, "key-1")
+	second := call(http.MethodPut, "/account/user/7", `{"name":"n"}`, true, "key-1")
+	if first.Code != 0 || second.Sign != first.Sign || m.calls != 2 {
+		t.Fatal("idempotent request was not reused", first.Code, first.Message, m.calls)
+	}
+
+	m.unsigned["account.user.get"] = true
+	if resp := call(http.MethodGet, "/account/user/12", "", false, ""); resp.Code != 0 {
+		t.Fatal("unsigned operation rejected", resp.Code, resp.Message)
+	}
+	if m.middlewares != 4 {
+		t.Fatal("middlewares were not applied", m.middlewares)
+	}
+}
@@ -2,6 +2,8 @@ package server
 
 import (
 	"bytes"
+	"github.com/36625090/involution/config"
+	"github.com/36625090/involution/transport"
 	"log"
 	"net/http"
 	"os"
@@ -57,3 +59,15 @@ func runCalls(logger *log.Logger) {
 	wg.Wait()
 	logger.Println("latency:", time.Now().Sub(since))
 }
+
+func TestNewIdempotencyStore(t *testing.T) {
+	if _, err := newIdempotencyStore("", &config.GlobalConfig{}); err == nil {
+		t.Fatal("default idempotency store must be redis")
+	}
+	if _, err := newIdempotencyStore(transport.IdempotencyStoreMemory, &config.GlobalConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newIdempotencyStore("file", &config.GlobalConfig{}); err == nil {
+		t.Fatal("unsupported idempotency store accepted")
+	}
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"errors"
+	"github.com/36625090/involution/authorities"
+	"github.com/36625090/involution/logical"
+	"github.com/36625090/involution/logical/codes"
+	"github.com/36625090/involution/tracing"
+	"github.com/36625090/involution/transport"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/go-hclog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	wsFrameResponse = "response"
+	wsFramePush     = "push"
+
+	wsWriteWait    = 10 * time.Second
+	wsPongWait     = 60 * time.Second
+	wsPingPeriod   = wsPongWait * 9 / 10
+	wsSendBuffer   = 64
+	wsMaxFrameSize = 4 << 20
+)
+
+var errWebSocketUnauthorized = errors.New("websocket requires an authorized account")
+
+//wsFrame 服务端发送的消息帧
+//type=response 为请求信封的签名响应，按请求顺序返回；type=push 为服务端推送
+type wsFrame struct {
+	Type     string               `json:"type"`
+	Response *transport.Response  `json:"response,omitempty"`
+	Push     *logical.PushMessage `json:"push,omitempty"`
+}
+
+//wsConn 单个WebSocket连接，写操作统一由writeLoop完成
+type wsConn struct {
+	conn   *websocket.Conn
+	send   chan *wsFrame
+	done   chan struct{}
+	once   sync.Once
+	logger hclog.Logger
+}
+
+//push 发送缓冲已满时丢弃推送消息，避免慢客户端阻塞其他连接
+func (c *wsConn) push(msg *logical.PushMessage) {
+	select {
+	case <-c.done:
+	case c.send <- &wsFrame{Type: wsFramePush, Push: msg}:
+	default:
+		c.logger.Warn("websocket send buffer full, push dropped", "id", msg.ID, "event", msg.Event)
+	}
+}
+
+func (c *wsConn) reply(resp *transport.Response) {
+	select {
+	case <-c.done:
+	case c.send <- &wsFrame{Type: wsFrameResponse, Response: resp}:
+	}
+}
+
+func (c *wsConn) close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+func (c *wsConn) writeLoop() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.close()
+	}()
+	for {
+		select {
+		case <-c.done:
+			return
+		case frame := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteJSON(frame); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+//initWebSocket 注册 /ws 通道
+//握手时使用令牌鉴权一次(Authorization头或token参数)，浏览器无法设置请求头时可使用client_id、key_version参数
+//之后客户端发送的每条文本消息为一个请求信封，按顺序验签、处理并返回签名响应
+func (m *Server) initWebSocket() error {
+	if err := m.pusher.subscribe(); err != nil {
+		return err
+	}
+
+	upgrader := websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+	}
+	if m.opts.Http.Cors {
+		upgrader.CheckOrigin = func(r *http.Request) bool { return true }
+	}
+
+	path := strings.Join([]string{m.opts.Http.Path, "ws"}, "/")
+	m.logger.Info("initialize handle", "path", path, "method", http.MethodGet)
+	m.httpTransport.GET(path, func(gCtx *gin.Context) {
+		wsHeaderFromQuery(gCtx, logical.HeaderAuthorizationKey, "token")
+		wsHeaderFromQuery(gCtx, logical.HeaderClientIDKey, "client_id")
+		wsHeaderFromQuery(gCtx, logical.HeaderSignKeyVersionKey, "key_version")
+
+		authorized, err := m.wsAuthorization(gCtx.GetHeader(logical.HeaderAuthorizationKey.String()))
+		if err != nil {
+			gCtx.AbortWithStatusJSON(http.StatusUnauthorized, &transport.Response{
+				Code: codes.CodeUnauthorized.Int(), Message: err.Error(), TraceID: gCtx.GetHeader(logical.HeaderTraceIDKey.String()),
+			})
+			return
+		}
+
+		conn, err := upgrader.Upgrade(gCtx.Writer, gCtx.Request, nil)
+		if err != nil {
+			m.logger.Error("websocket upgrade", "err", err)
+			return
+		}
+		m.serveWebSocket(gCtx, conn, authorized)
+	})
+	return nil
+}
+
+//wsAuthorization 握手鉴权，验证策略为allow时允许匿名连接，匿名连接不接收推送
+func (m *Server) wsAuthorization(token string) (*authorities.Authorized, error) {
+	if nil == m.authorization {
+		return nil, errors.New("authorization unavailable")
+	}
+	if m.authorization.Settings().DefaultPolicy == authorities.AuthorizationPolicyAllow {
+		return nil, nil
+	}
+	if token == "" {
+		return nil, errWebSocketUnauthorized
+	}
+	return m.authorization.Authentication(m.ctx, token)
+}
+
+func (m *Server) serveWebSocket(gCtx *gin.Context, conn *websocket.Conn, authorized *authorities.Authorized) {
+	c := &wsConn{
+		conn:   conn,
+		send:   make(chan *wsFrame, wsSendBuffer),
+		done:   make(chan struct{}),
+		logger: m.logger,
+	}
+	if authorized != nil && authorized.ID != "" {
+		m.pusher.register(authorized.ID, c)
+		defer m.pusher.unregister(authorized.ID, c)
+	}
+	defer c.close()
+	go c.writeLoop()
+
+	conn.SetReadLimit(wsMaxFrameSize)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	handle := func(ctx *transport.Context) (err error) {
+		m.connection.Inc()
+		defer func() {
+			m.connection.Dec()
+			if err != nil {
+				m.connection.Error()
+			}
+		}()
+		return m.handleBackendRequest(ctx, authorized)
+	}
+
+	request := gCtx.Request
+	for {
+		messageType, body, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				m.logger.Warn("websocket read", "err", err)
+			}
+			return
+		}
+		if messageType != websocket.TextMessage {
+			continue
+		}
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		//消息按顺序处理，每个请求信封使用新的链路
+		trace := logical.NewTraceContext()
+		ctx, span := tracing.StartServer(request.Context(), nil, trace, "websocket")
+		trace.Inject(gCtx.Request.Header)
+		gCtx.Request = request.WithContext(ctx)
+		resp := m.httpTransport.ServeEnvelope(gCtx, body, handle)
+		span.End()
+		c.reply(resp)
+	}
+}
+
+func wsHeaderFromQuery(gCtx *gin.Context, header logical.HeaderKey, query string) {
+	if gCtx.GetHeader(header.String()) != "" {
+		return
+	}
+	if value := gCtx.Query(query); value != "" {
+		gCtx.Request.Header.Set(header.String(), value)
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/36625090/involution/authorities"
+	"github.com/36625090/involution/config"
+	"github.com/36625090/involution/logical"
+	"github.com/36625090/involution/logical/codes"
+	"github.com/36625090/involution/option"
+	"github.com/36625090/involution/transport"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/go-hclog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testAuthorization struct{}
+
+func (testAuthorization) Settings() *authorities.Settings {
+	return &authorities.Settings{}
+}
+
+func (testAuthorization) Authentication(ctx context.Context, token string) (*authorities.Authorized, error) {
+	if token != "token-1" {
+		return nil, errors.New("invalid token")
+	}
+	return &authorities.Authorized{ID: "1", Account: "user"}, nil
+}
+
+func (testAuthorization) TokenHandler() authorities.TokenHandler {
+	return nil
+}
+
+type testBackend struct{}
+
+func (testBackend) Initialize(context.Context) error { return nil }
+func (testBackend) HandleRequest(ctx context.Context, args *logical.Args) (*logical.Reply, *logical.WrapperError) {
+	if args.Operation == "missing" {
+		return nil, &logical.WrapperError{Code: codes.CodeOperationNotFound, Err: errors.New("operation not exists")}
+	}
+	return &logical.Reply{Data: args.Authorized.ID + ":" + args.Operation}, nil
+}
+func (testBackend) Documents(context.Context) (*logical.DocumentsReply, error) { return nil, nil }
+func (testBackend) Cleanup(context.Context)                                    {}
+func (testBackend) BackendName() string                                        { return "account" }
+func (testBackend) BackendDescription() string                                 { return "" }
+
+func newTestWebSocketServer(t *testing.T) *Server {
+	gin.SetMode(gin.ReleaseMode)
+	logger := hclog.NewInterceptLogger(&hclog.LoggerOptions{Output: hclog.DefaultOutput, Level: hclog.Off})
+	tr, err := transport.NewTransport(gin.New(), &transport.Settings{DefaultPolicy: transport.SignPolicyAllow}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pusher, err := newPushHub(PushBrokerMemory, nil, "test", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Server{
+		ctx:           context.Background(),
+		logger:        logger,
+		opts:          &option.Options{},
+		globalConfig:  &config.GlobalConfig{},
+		authorization: testAuthorization{},
+		httpTransport: tr,
+		connection:    &Connection{},
+		pusher:        pusher,
+		backends:      map[string]logical.Backend{"account": testBackend{}},
+	}
+	if err := m.initWebSocket(); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestServer_WebSocket(t *testing.T) {
+	m := newTestWebSocketServer(t)
+	ts := httptest.NewServer(m.httpTransport)
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != 401 {
+		t.Fatal("unauthorized connection accepted")
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?token=token-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	envelope := `{"method":"account.user.home","data":{},"timestamp":1,"version":"1.0","sign":"-","sign_type":"md5"}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(envelope)); err != nil {
+		t.Fatal(err)
+	}
+	var frame wsFrame
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Type != wsFrameResponse || frame.Response.Content != "1:home" || frame.Response.TraceID == "" {
+		t.Fatal("unexpected response frame", frame.Type, frame.Response)
+	}
+
+	if err := m.Pusher().Push(context.Background(), "1", "notice", map[string]string{"text": "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	frame = wsFrame{}
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Type != wsFramePush || frame.Push.Event != "notice" || string(frame.Push.Data) != `{"text":"hello"}` {
+		t.Fatal("unexpected push frame", frame.Type, frame.Push)
+	}
+}
+
+func TestNewServer_WebSocketDisabled(t *testing.T) {
+	logger := hclog.NewInterceptLogger(&hclog.LoggerOptions{Output: hclog.DefaultOutput, Level: hclog.Off})
+	opts := &option.Options{Http: option.Http{WebSocketPush: PushBrokerRedis}}
+	m, err := NewServer(opts, &config.GlobalConfig{}, nil, logger)
+	if err != nil {
+		t.Fatal("push broker must not be required without websocket", err)
+	}
+	if err := m.Pusher().Push(context.Background(), "1", "notice", nil); err != errPushDisabled {
+		t.Fatal("unexpected push error", err)
+	}
+	m.Cleanup()
+
+	opts.Http.WebSocket = true
+	if _, err := NewServer(opts, &config.GlobalConfig{}, nil, logger); err == nil {
+		t.Fatal("redis push broker requires redis config")
+	}
+}
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	"context"
+	"github.com/go-various/redisplus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/redis.v5"
+	"strings"
+)
+
+//RedisCli 返回携带请求上下文的redis客户端，每次调用创建ctx中span的子span
+//redis.v5的命令不携带上下文，因此按请求包装；未开启追踪或ctx中没有span时返回cli本身，不创建根span
+//NativeCmd返回的单机客户端为每条命令创建span，集群客户端不支持
+func RedisCli(ctx context.Context, cli redisplus.RedisCli) redisplus.RedisCli {
+	if !Enabled() || cli == nil || !trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		return cli
+	}
+	return &redisCli{RedisCli: cli, ctx: ctx}
+}
+
+type redisCli struct {
+	redisplus.RedisCli
+	ctx context.Context
+}
+
+func (r *redisCli) NativeCmd() redisplus.RedisCmd {
+	client, ok := r.RedisCli.NativeCmd().(*redis.Client)
+	if !ok {
+		return r.RedisCli.NativeCmd()
+	}
+	//WithContext复制客户端，包装只作用于该请求
+	client = client.WithContext(r.ctx)
+	client.WrapProcess(func(oldProcess func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) (err error) {
+			name := "redis"
+			if fields := strings.Fields(cmd.String()); len(fields) > 0 {
+				name = strings.TrimSuffix(fields[0], ":")
+			}
+			defer r.span(name)(&err)
+			return oldProcess(cmd)
+		}
+	})
+	return client
+}
+
+//span 开始redis操作的span，返回的函数按操作结果结束span，redis.Nil不作为错误
+func (r *redisCli) span(operation string) func(err *error) {
+	_, span := Start(r.ctx, "redis "+operation, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.system", "redis"), attribute.String("db.operation", operation)))
+	return func(err *error) {
+		if *err == redis.Nil {
+			End(span, nil)
+		} else {
+			End(span, *err)
+		}
+	}
+}
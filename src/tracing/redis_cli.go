@@ -0,0 +1,328 @@
+package tracing
+
+import (
+	"github.com/go-various/redisplus"
+	"gopkg.in/redis.v5"
+)
+
+//redisCli的每个方法创建一个span，参考RedisCli
+
+func (r *redisCli) Scan(cursor uint64, match string, count int64) (_ []string, err error) {
+	defer r.span("Scan")(&err)
+	return r.RedisCli.Scan(cursor, match, count)
+}
+
+func (r *redisCli) SetNX(key string, value []byte, duration string) (_ bool, err error) {
+	defer r.span("SetNX")(&err)
+	return r.RedisCli.SetNX(key, value, duration)
+}
+
+func (r *redisCli) Get(key string) (_ []byte, err error) {
+	defer r.span("Get")(&err)
+	return r.RedisCli.Get(key)
+}
+
+func (r *redisCli) Set(key string, value []byte, duration string) (err error) {
+	defer r.span("Set")(&err)
+	return r.RedisCli.Set(key, value, duration)
+}
+
+func (r *redisCli) Del(keys ...string) (_ int64, err error) {
+	defer r.span("Del")(&err)
+	return r.RedisCli.Del(keys...)
+}
+
+func (r *redisCli) Expire(key string, duration string) (err error) {
+	defer r.span("Expire")(&err)
+	return r.RedisCli.Expire(key, duration)
+}
+
+func (r *redisCli) HSetNX(key, field string, value []byte) (err error) {
+	defer r.span("HSetNX")(&err)
+	return r.RedisCli.HSetNX(key, field, value)
+}
+
+func (r *redisCli) HSet(key, field string, value []byte) (err error) {
+	defer r.span("HSet")(&err)
+	return r.RedisCli.HSet(key, field, value)
+}
+
+func (r *redisCli) HMSet(key string, Values map[string][]byte) (err error) {
+	defer r.span("HMSet")(&err)
+	return r.RedisCli.HMSet(key, Values)
+}
+
+func (r *redisCli) HGet(key, field string) (_ []byte, err error) {
+	defer r.span("HGet")(&err)
+	return r.RedisCli.HGet(key, field)
+}
+
+func (r *redisCli) HMGet(key string, fields ...string) (_ [][]byte, err error) {
+	defer r.span("HMGet")(&err)
+	return r.RedisCli.HMGet(key, fields...)
+}
+
+func (r *redisCli) HGetAll(key string) (_ map[string][]byte, err error) {
+	defer r.span("HGetAll")(&err)
+	return r.RedisCli.HGetAll(key)
+}
+
+func (r *redisCli) HDel(key string, fields ...string) (_ int64, err error) {
+	defer r.span("HDel")(&err)
+	return r.RedisCli.HDel(key, fields...)
+}
+
+func (r *redisCli) HLen(key string) (_ int64, err error) {
+	defer r.span("HLen")(&err)
+	return r.RedisCli.HLen(key)
+}
+
+func (r *redisCli) HKeys(key string) (_ []string, err error) {
+	defer r.span("HKeys")(&err)
+	return r.RedisCli.HKeys(key)
+}
+
+func (r *redisCli) HValues(key string) (_ [][]byte, err error) {
+	defer r.span("HValues")(&err)
+	return r.RedisCli.HValues(key)
+}
+
+func (r *redisCli) HExists(key, field string) (_ bool, err error) {
+	defer r.span("HExists")(&err)
+	return r.RedisCli.HExists(key, field)
+}
+
+func (r *redisCli) LRem(key string, count int64, value []byte) (_ int64, err error) {
+	defer r.span("LRem")(&err)
+	return r.RedisCli.LRem(key, count, value)
+}
+
+func (r *redisCli) LIndex(key string, index int64) (_ []byte, err error) {
+	defer r.span("LIndex")(&err)
+	return r.RedisCli.LIndex(key, index)
+}
+
+func (r *redisCli) LTrim(key string, start, stop int64) (err error) {
+	defer r.span("LTrim")(&err)
+	return r.RedisCli.LTrim(key, start, stop)
+}
+
+func (r *redisCli) LSet(key string, index int64, value []byte) (err error) {
+	defer r.span("LSet")(&err)
+	return r.RedisCli.LSet(key, index, value)
+}
+
+func (r *redisCli) LPush(key string, values ...[]byte) (_ int64, err error) {
+	defer r.span("LPush")(&err)
+	return r.RedisCli.LPush(key, values...)
+}
+
+func (r *redisCli) LAppend(key string, values ...[]byte) (_ int64, err error) {
+	defer r.span("LAppend")(&err)
+	return r.RedisCli.LAppend(key, values...)
+}
+
+func (r *redisCli) LPop(key string) (_ []byte, err error) {
+	defer r.span("LPop")(&err)
+	return r.RedisCli.LPop(key)
+}
+
+func (r *redisCli) LRPop(key string) (_ []byte, err error) {
+	defer r.span("LRPop")(&err)
+	return r.RedisCli.LRPop(key)
+}
+
+func (r *redisCli) LRange(key string, start, stop int64) (_ [][]byte, err error) {
+	defer r.span("LRange")(&err)
+	return r.RedisCli.LRange(key, start, stop)
+}
+
+func (r *redisCli) LLen(key string) (_ int64, err error) {
+	defer r.span("LLen")(&err)
+	return r.RedisCli.LLen(key)
+}
+
+func (r *redisCli) LInsert(key string, op redisplus.InsertOP, pivot, value []byte) (_ int64, err error) {
+	defer r.span("LInsert")(&err)
+	return r.RedisCli.LInsert(key, op, pivot, value)
+}
+
+func (r *redisCli) SLen(key string) (_ int64, err error) {
+	defer r.span("SLen")(&err)
+	return r.RedisCli.SLen(key)
+}
+
+func (r *redisCli) SAdd(key string, values ...[]byte) (_ int64, err error) {
+	defer r.span("SAdd")(&err)
+	return r.RedisCli.SAdd(key, values...)
+}
+
+func (r *redisCli) SRem(key string, values ...[]byte) (_ int64, err error) {
+	defer r.span("SRem")(&err)
+	return r.RedisCli.SRem(key, values...)
+}
+
+func (r *redisCli) SPop(key string) (_ []byte, err error) {
+	defer r.span("SPop")(&err)
+	return r.RedisCli.SPop(key)
+}
+
+func (r *redisCli) SPopN(key string, count int64) (_ [][]byte, err error) {
+	defer r.span("SPopN")(&err)
+	return r.RedisCli.SPopN(key, count)
+}
+
+func (r *redisCli) SDiff(keys ...string) (_ [][]byte, err error) {
+	defer r.span("SDiff")(&err)
+	return r.RedisCli.SDiff(keys...)
+}
+
+func (r *redisCli) SDiffMerge(destination string, keys ...string) (_ int64, err error) {
+	defer r.span("SDiffMerge")(&err)
+	return r.RedisCli.SDiffMerge(destination, keys...)
+}
+
+func (r *redisCli) SInter(keys ...string) (_ [][]byte, err error) {
+	defer r.span("SInter")(&err)
+	return r.RedisCli.SInter(keys...)
+}
+
+func (r *redisCli) SInterMerge(destination string, keys ...string) (_ int64, err error) {
+	defer r.span("SInterMerge")(&err)
+	return r.RedisCli.SInterMerge(destination, keys...)
+}
+
+func (r *redisCli) SUnion(keys ...string) (_ [][]byte, err error) {
+	defer r.span("SUnion")(&err)
+	return r.RedisCli.SUnion(keys...)
+}
+
+func (r *redisCli) SUnionMerge(destination string, keys ...string) (_ int64, err error) {
+	defer r.span("SUnionMerge")(&err)
+	return r.RedisCli.SUnionMerge(destination, keys...)
+}
+
+func (r *redisCli) ZLen(key string) (_ int64, err error) {
+	defer r.span("ZLen")(&err)
+	return r.RedisCli.ZLen(key)
+}
+
+func (r *redisCli) ZCount(key string, min, max float64) (_ int64, err error) {
+	defer r.span("ZCount")(&err)
+	return r.RedisCli.ZCount(key, min, max)
+}
+
+func (r *redisCli) ZLexCount(key, min, max string) (_ int64, err error) {
+	defer r.span("ZLexCount")(&err)
+	return r.RedisCli.ZLexCount(key, min, max)
+}
+
+func (r *redisCli) ZAdd(key string, members ...*redisplus.ZMember) (_ int64, err error) {
+	defer r.span("ZAdd")(&err)
+	return r.RedisCli.ZAdd(key, members...)
+}
+
+func (r *redisCli) ZRem(key string, members ...*redisplus.ZMember) (_ int64, err error) {
+	defer r.span("ZRem")(&err)
+	return r.RedisCli.ZRem(key, members...)
+}
+
+func (r *redisCli) ZRemRangeByLex(key, min, max string) (_ int64, err error) {
+	defer r.span("ZRemRangeByLex")(&err)
+	return r.RedisCli.ZRemRangeByLex(key, min, max)
+}
+
+func (r *redisCli) ZRemRangeByScore(key string, min, max float64) (_ int64, err error) {
+	defer r.span("ZRemRangeByScore")(&err)
+	return r.RedisCli.ZRemRangeByScore(key, min, max)
+}
+
+func (r *redisCli) ZRemRangeByRank(key string, start, stop int64) (_ int64, err error) {
+	defer r.span("ZRemRangeByRank")(&err)
+	return r.RedisCli.ZRemRangeByRank(key, start, stop)
+}
+
+func (r *redisCli) ZRange(key string, start, stop int64, reverse, withScores bool) (_ []*redisplus.ZMember, err error) {
+	defer r.span("ZRange")(&err)
+	return r.RedisCli.ZRange(key, start, stop, reverse, withScores)
+}
+
+func (r *redisCli) ZRangeByScore(key string, rangeBy redisplus.ZRangeBy, reverse, withScores bool) (_ []*redisplus.ZMember, err error) {
+	defer r.span("ZRangeByScore")(&err)
+	return r.RedisCli.ZRangeByScore(key, rangeBy, reverse, withScores)
+}
+
+func (r *redisCli) ZRangeByLex(key string, rangeBy redisplus.ZRangeBy, reverse bool) (_ []*redisplus.ZMember, err error) {
+	defer r.span("ZRangeByLex")(&err)
+	return r.RedisCli.ZRangeByLex(key, rangeBy, reverse)
+}
+
+func (r *redisCli) ZRank(key string, member []byte, reverse bool) (_ int64, err error) {
+	defer r.span("ZRank")(&err)
+	return r.RedisCli.ZRank(key, member, reverse)
+}
+
+func (r *redisCli) ZIncr(key string, member *redisplus.ZMember) (_ float64, err error) {
+	defer r.span("ZIncr")(&err)
+	return r.RedisCli.ZIncr(key, member)
+}
+
+func (r *redisCli) ZIncrNX(key string, member *redisplus.ZMember) (_ float64, err error) {
+	defer r.span("ZIncrNX")(&err)
+	return r.RedisCli.ZIncrNX(key, member)
+}
+
+func (r *redisCli) ZInterMerge(destination string, merge *redisplus.ZMerge, keys ...string) (_ int64, err error) {
+	defer r.span("ZInterMerge")(&err)
+	return r.RedisCli.ZInterMerge(destination, merge, keys...)
+}
+
+func (r *redisCli) ZUnionMerge(destination string, merge *redisplus.ZMerge, keys ...string) (_ int64, err error) {
+	defer r.span("ZUnionMerge")(&err)
+	return r.RedisCli.ZUnionMerge(destination, merge, keys...)
+}
+
+func (r *redisCli) GeoAdd(key string, geoLocation ...*redis.GeoLocation) (_ int64, err error) {
+	defer r.span("GeoAdd")(&err)
+	return r.RedisCli.GeoAdd(key, geoLocation...)
+}
+
+func (r *redisCli) GeoRadius(key string, longitude, latitude float64, query *redis.GeoRadiusQuery) (_ []redis.GeoLocation, err error) {
+	defer r.span("GeoRadius")(&err)
+	return r.RedisCli.GeoRadius(key, longitude, latitude, query)
+}
+
+func (r *redisCli) GeoRadiusByMember(key, member string, query *redis.GeoRadiusQuery) (_ []redis.GeoLocation, err error) {
+	defer r.span("GeoRadiusByMember")(&err)
+	return r.RedisCli.GeoRadiusByMember(key, member, query)
+}
+
+func (r *redisCli) GeoDist(key string, member1, member2, unit string) (_ float64, err error) {
+	defer r.span("GeoDist")(&err)
+	return r.RedisCli.GeoDist(key, member1, member2, unit)
+}
+
+func (r *redisCli) GeoHash(key string, members ...string) (_ []string, err error) {
+	defer r.span("GeoHash")(&err)
+	return r.RedisCli.GeoHash(key, members...)
+}
+
+func (r *redisCli) GeoPos(key string, members ...string) (_ []*redis.GeoPos, err error) {
+	defer r.span("GeoPos")(&err)
+	return r.RedisCli.GeoPos(key, members...)
+}
+
+func (r *redisCli) GeoCalculateDistance(key string, location1 redisplus.Location, location2 redisplus.Location) (_ float64, err error) {
+	defer r.span("GeoCalculateDistance")(&err)
+	return r.RedisCli.GeoCalculateDistance(key, location1, location2)
+}
+
+func (r *redisCli) Subscribe(channels ...string) (_ *redis.PubSub, err error) {
+	defer r.span("Subscribe")(&err)
+	return r.RedisCli.Subscribe(channels...)
+}
+
+func (r *redisCli) PSubscribe(channels ...string) (_ *redis.PubSub, err error) {
+	defer r.span("PSubscribe")(&err)
+	return r.RedisCli.PSubscribe(channels...)
+}
@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"github.com/36625090/involution/logical"
+	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"strconv"
+)
+
+var errRestyRetry = errors.New("retrying request")
+
+//restyParentKey 请求的原始上下文，重试时新的span仍作为其子span
+type restyParentKey struct{}
+
+//RestyHeaders 每次请求写入上下文中的链路追踪请求头(X-Trace-ID traceparent tracestate)，已设置的请求头不覆盖
+//请求需通过SetContext(ctx)传入请求上下文，参考logical.WithTraceHeaders；需在Resty之前注册，开启追踪时traceparent由Resty替换
+func RestyHeaders(client *resty.Client) *resty.Client {
+	client.OnBeforeRequest(func(c *resty.Client, r *resty.Request) error {
+		for key, value := range logical.TraceHeadersFromContext(r.Context()) {
+			if r.Header.Get(key) == "" {
+				r.Header.Set(key, value)
+			}
+			if r.RawRequest != nil && r.RawRequest.Header.Get(key) == "" {
+				r.RawRequest.Header.Set(key, value)
+			}
+		}
+		return nil
+	})
+	return client
+}
+
+//Resty 为resty客户端的每次请求创建span并写入traceparent，请求需通过SetContext(ctx)传入请求上下文
+//service 为调用的服务名称，写入peer.service属性
+func Resty(client *resty.Client, service string) *resty.Client {
+	client.OnBeforeRequest(func(c *resty.Client, r *resty.Request) error {
+		parent, retry := r.Context().Value(restyParentKey{}).(context.Context)
+		if retry {
+			End(trace.SpanFromContext(r.Context()), errRestyRetry)
+		} else {
+			parent = r.Context()
+		}
+		ctx, _ := Start(parent, r.Method+" "+service, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("peer.service", service),
+				attribute.String("http.method", r.Method),
+				attribute.String("http.url", r.URL),
+			))
+		ctx = context.WithValue(ctx, restyParentKey{}, parent)
+		r.SetContext(ctx)
+		if r.RawRequest != nil {
+			r.RawRequest = r.RawRequest.WithContext(ctx)
+			Inject(ctx, r.RawRequest.Header)
+		}
+		return nil
+	})
+	client.OnAfterResponse(func(c *resty.Client, r *resty.Response) error {
+		span := trace.SpanFromContext(r.Request.Context())
+		span.SetAttributes(attribute.Int("http.status_code", r.StatusCode()))
+		var err error
+		if r.StatusCode() >= 500 {
+			err = errors.New("http status " + strconv.Itoa(r.StatusCode()))
+		}
+		End(span, err)
+		return nil
+	})
+	client.OnError(func(r *resty.Request, err error) {
+		//已结束的span再次结束时忽略
+		End(trace.SpanFromContext(r.Context()), err)
+	})
+	return client
+}
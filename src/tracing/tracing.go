@@ -0,0 +1,243 @@
+package tracing
+
+import (
+	"context"
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/36625090/involution/logical"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+//TracerName 本框架创建span使用的tracer名称
+const TracerName = "github.com/36625090/involution"
+
+const (
+	ExporterOTLPGRPC = "otlp-grpc"
+	ExporterOTLPHTTP = "otlp-http"
+	ExporterStdout   = "stdout"
+	//ExporterFile 每行一个JSON格式的span，用于本地调试
+	ExporterFile = "file"
+)
+
+//span属性
+const (
+	AttrBackend   = attribute.Key("involution.backend")
+	AttrEndpoint  = attribute.Key("involution.endpoint")
+	AttrOperation = attribute.Key("involution.operation")
+	AttrClientID  = attribute.Key("involution.client_id")
+	AttrCode      = attribute.Key("involution.code")
+)
+
+var errUnsupportedExporter = errors.New("unsupported tracing exporter")
+
+var enabled int32
+
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+//Settings 链路追踪配置
+type Settings struct {
+	//ServiceName 服务名称，写入resource的service.name
+	ServiceName string
+	//Exporter otlp-grpc(默认) otlp-http stdout file
+	Exporter string
+	//Endpoint OTLP接收地址，为空时使用OTEL_EXPORTER_OTLP_ENDPOINT或默认地址
+	Endpoint string
+	//Insecure OTLP不使用TLS
+	Insecure bool
+	//File file导出的文件路径
+	File string
+	//SampleRatio 没有上游采样标记的请求的采样比例，默认1
+	SampleRatio float64
+}
+
+//Setup 初始化全局TracerProvider，返回的函数用于停止时刷新并关闭导出器
+func Setup(ctx context.Context, settings *Settings) (func(context.Context) error, error) {
+	exporter, closer, err := newExporter(ctx, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	ratio := settings.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", settings.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithIDGenerator(&idGenerator{}),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	atomic.StoreInt32(&enabled, 1)
+
+	return func(ctx context.Context) error {
+		atomic.StoreInt32(&enabled, 0)
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			if cerr := closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}, nil
+}
+
+func newExporter(ctx context.Context, settings *Settings) (sdktrace.SpanExporter, *os.File, error) {
+	switch settings.Exporter {
+	case "", ExporterOTLPGRPC:
+		var opts []otlptracegrpc.Option
+		if settings.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(settings.Endpoint))
+		}
+		if settings.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err := otlptracegrpc.New(ctx, opts...)
+		return exporter, nil, err
+	case ExporterOTLPHTTP:
+		var opts []otlptracehttp.Option
+		if settings.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(settings.Endpoint))
+		}
+		if settings.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, opts...)
+		return exporter, nil, err
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		return exporter, nil, err
+	case ExporterFile:
+		if settings.File == "" {
+			return nil, nil, errors.New("tracing file exporter requires file")
+		}
+		if err := os.MkdirAll(filepath.Dir(settings.File), 0755); err != nil {
+			return nil, nil, err
+		}
+		f, err := os.OpenFile(settings.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		return exporter, f, nil
+	}
+	return nil, nil, fmt.Errorf("%s: %s", errUnsupportedExporter, settings.Exporter)
+}
+
+//Enabled 是否已通过Setup开启链路追踪
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) == 1
+}
+
+//Start 开始ctx中span的子span，未开启时返回不记录的span
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(TracerName).Start(ctx, name, opts...)
+}
+
+//End 结束span，err不为空时记录错误
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, err.Error())
+	}
+	span.End()
+}
+
+//StartServer 开始服务端span
+//上游traceparent合法时作为父span，否则新链路的trace-id使用trace中由X-Trace-ID转换的值；
+//开启追踪时trace的trace-id、span-id和flags替换为该span的值，使日志、响应头与导出的span一致
+//header 必须为上游的原始请求头，trace没有上游parent-id时开始新的链路
+func StartServer(ctx context.Context, header http.Header, t *logical.TraceContext, name string) (context.Context, trace.Span) {
+	if !Enabled() {
+		return ctx, trace.SpanFromContext(ctx)
+	}
+	opts := []trace.SpanStartOption{trace.WithSpanKind(trace.SpanKindServer)}
+	if t.ParentID != "" {
+		ctx = propagator.Extract(ctx, propagation.HeaderCarrier(header))
+	} else {
+		opts = append(opts, trace.WithNewRoot())
+		if id, err := trace.TraceIDFromHex(t.TraceID); err == nil {
+			ctx = context.WithValue(ctx, traceIDKey{}, id)
+		}
+	}
+
+	ctx, span := Start(ctx, name, opts...)
+	sc := span.SpanContext()
+	t.TraceID, t.SpanID, t.Flags = sc.TraceID().String(), sc.SpanID().String(), sc.TraceFlags().String()
+	return ctx, span
+}
+
+//Detach 返回只携带ctx中span的新上下文，用于不希望随请求取消的后端调用
+func Detach(ctx context.Context) context.Context {
+	return trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx))
+}
+
+//Inject 将ctx中的span写入调用其他服务的请求头(traceparent tracestate)
+func Inject(ctx context.Context, header http.Header) {
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+//Method 请求信封method(backend.endpoint.operation)对应的span属性
+func Method(method string) []attribute.KeyValue {
+	parts := strings.SplitN(method, ".", 3)
+	keys := []attribute.Key{AttrBackend, AttrEndpoint, AttrOperation}
+	attrs := make([]attribute.KeyValue, 0, len(parts))
+	for i, part := range parts {
+		attrs = append(attrs, keys[i].String(part))
+	}
+	return attrs
+}
+
+//Args 后端请求对应的span属性
+func Args(args *logical.Args) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		AttrBackend.String(args.Backend),
+		AttrEndpoint.String(args.Endpoint),
+		AttrOperation.String(args.Operation),
+	}
+	if id := args.GetClientID(); id != "" {
+		attrs = append(attrs, AttrClientID.String(id))
+	}
+	return attrs
+}
+
+type traceIDKey struct{}
+
+//idGenerator 随机生成ID，上下文指定trace-id时新链路使用该trace-id
+type idGenerator struct{}
+
+func (g *idGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	traceID, ok := ctx.Value(traceIDKey{}).(trace.TraceID)
+	if !ok || !traceID.IsValid() {
+		_, _ = crand.Read(traceID[:])
+	}
+	return traceID, g.NewSpanID(ctx, traceID)
+}
+
+func (g *idGenerator) NewSpanID(ctx context.Context, traceID trace.TraceID) trace.SpanID {
+	var spanID trace.SpanID
+	for !spanID.IsValid() {
+		_, _ = crand.Read(spanID[:])
+	}
+	return spanID
+}
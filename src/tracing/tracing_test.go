@@ -0,0 +1,229 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/36625090/involution/logical"
+	"github.com/go-resty/resty/v2"
+	"github.com/go-various/redisplus"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type exportedSpan struct {
+	Name        string
+	SpanContext struct {
+		TraceID string
+		SpanID  string
+	}
+	Parent struct {
+		SpanID string
+	}
+	Attributes []struct {
+		Key   string
+		Value struct {
+			Value interface{}
+		}
+	}
+}
+
+func (s *exportedSpan) attribute(key string) interface{} {
+	for _, attr := range s.Attributes {
+		if attr.Key == key {
+			return attr.Value.Value
+		}
+	}
+	return nil
+}
+
+//setupFile 使用file导出器开启链路追踪，返回的函数停止追踪并按名称返回导出的span
+func setupFile(t *testing.T) func() map[string]*exportedSpan {
+	file := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := Setup(context.Background(), &Settings{ServiceName: "test", Exporter: ExporterFile, File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func() map[string]*exportedSpan {
+		if err := shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		spans := map[string]*exportedSpan{}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			span := &exportedSpan{}
+			if err := json.Unmarshal(scanner.Bytes(), span); err != nil {
+				t.Fatal(err)
+			}
+			spans[span.Name] = span
+		}
+		return spans
+	}
+}
+
+func TestStartServer(t *testing.T) {
+	export := setupFile(t)
+
+	header := http.Header{}
+	header.Set(logical.HeaderTraceparentKey.String(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	trace := logical.ResolveTrace(header)
+	ctx, span := StartServer(context.Background(), header, trace, "POST /api")
+	_, child := Start(ctx, "account.user.login")
+	child.SetAttributes(Method("account.user.login")...)
+	child.End()
+	span.End()
+
+	//未携带traceparent时trace-id由X-Trace-ID转换
+	root := http.Header{}
+	root.Set(logical.HeaderTraceIDKey.String(), "8f7c4e5a-0b1d-4c3e-9f2a-6d5b4a3c2e1f")
+	rootTrace := logical.ResolveTrace(root)
+	_, rootSpan := StartServer(ctx, root, rootTrace, "GET /ws")
+	rootSpan.End()
+
+	spans := export()
+	server, login, ws := spans["POST /api"], spans["account.user.login"], spans["GET /ws"]
+	if server == nil || login == nil || ws == nil {
+		t.Fatal("missing spans", spans)
+	}
+	if server.SpanContext.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || server.Parent.SpanID != "00f067aa0ba902b7" {
+		t.Fatal("server span does not continue upstream trace", server)
+	}
+	if trace.TraceID != server.SpanContext.TraceID || trace.SpanID != server.SpanContext.SpanID || trace.Flags != "01" {
+		t.Fatal("trace context does not match server span", trace, server)
+	}
+	if login.SpanContext.TraceID != server.SpanContext.TraceID || login.Parent.SpanID != server.SpanContext.SpanID {
+		t.Fatal("unexpected child span", login)
+	}
+	if login.attribute(string(AttrBackend)) != "account" || login.attribute(string(AttrOperation)) != "login" {
+		t.Fatal("unexpected attributes", login.Attributes)
+	}
+	if ws.SpanContext.TraceID != "8f7c4e5a0b1d4c3e9f2a6d5b4a3c2e1f" || ws.Parent.SpanID != "0000000000000000" {
+		t.Fatal("new trace must use X-Trace-ID as trace-id", ws)
+	}
+	if rootTrace.Traceparent() != "00-8f7c4e5a0b1d4c3e9f2a6d5b4a3c2e1f-"+ws.SpanContext.SpanID+"-01" {
+		t.Fatal("unexpected traceparent", rootTrace.Traceparent())
+	}
+}
+
+func TestStartServer_Disabled(t *testing.T) {
+	trace := logical.NewTraceContext()
+	spanID := trace.SpanID
+	_, span := StartServer(context.Background(), http.Header{}, trace, "POST /api")
+	span.End()
+	if span.SpanContext().IsValid() || trace.SpanID != spanID {
+		t.Fatal("disabled tracing must not change trace context")
+	}
+}
+
+func TestResty(t *testing.T) {
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get(logical.HeaderTraceparentKey.String())
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	export := setupFile(t)
+	ctx, parent := Start(context.Background(), "handler")
+	if _, err := Resty(resty.New(), "userservice").R().SetContext(ctx).Get(srv.URL + "/users"); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := export()
+	client := spans["GET userservice"]
+	if client == nil {
+		t.Fatal("missing client span", spans)
+	}
+	if client.Parent.SpanID != parent.SpanContext().SpanID().String() {
+		t.Fatal("client span must be child of request span", client)
+	}
+	if traceparent != "00-"+client.SpanContext.TraceID+"-"+client.SpanContext.SpanID+"-01" {
+		t.Fatal("unexpected traceparent", traceparent)
+	}
+	if code, _ := client.attribute("http.status_code").(float64); code != http.StatusBadGateway {
+		t.Fatal("unexpected status code", client.Attributes)
+	}
+}
+
+func TestRestyHeaders(t *testing.T) {
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer srv.Close()
+
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := logical.WithTraceHeaders(context.Background(), map[string]string{
+		logical.HeaderTraceIDKey.String():     "trace-1",
+		logical.HeaderTraceparentKey.String(): traceparent,
+		logical.HeaderTracestateKey.String():  "vendor=1",
+	})
+	client := RestyHeaders(resty.New())
+	if _, err := client.R().SetContext(ctx).Get(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get(logical.HeaderTraceIDKey.String()) != "trace-1" || header.Get(logical.HeaderTraceparentKey.String()) != traceparent ||
+		header.Get(logical.HeaderTracestateKey.String()) != "vendor=1" {
+		t.Fatal("trace headers not propagated", header)
+	}
+
+	//开启追踪时traceparent为客户端span
+	export := setupFile(t)
+	Resty(client, "userservice")
+	if _, err := client.R().SetContext(ctx).Get(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	spans := export()
+	span := spans["GET userservice"]
+	if span == nil {
+		t.Fatal("missing client span", spans)
+	}
+	if header.Get(logical.HeaderTraceIDKey.String()) != "trace-1" ||
+		header.Get(logical.HeaderTraceparentKey.String()) != "00-"+span.SpanContext.TraceID+"-"+span.SpanContext.SpanID+"-01" {
+		t.Fatal("unexpected trace headers with tracing enabled", header)
+	}
+}
+
+func TestRedisCli(t *testing.T) {
+	//未启动的redis，命令返回连接错误
+	cli, err := redisplus.NewRedisCli(&redisplus.Config{Addrs: []string{"127.0.0.1:1"}}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	export := setupFile(t)
+	if RedisCli(context.Background(), cli) != cli {
+		t.Fatal("redis without request span must not be traced")
+	}
+	ctx, parent := Start(context.Background(), "handler")
+	traced := RedisCli(ctx, cli)
+	if err := traced.Set("key", []byte("value"), ""); err == nil {
+		t.Fatal("unexpected redis connection")
+	}
+	_ = traced.NativeCmd().Get("key").Err()
+	parent.End()
+
+	spans := export()
+	set, get := spans["redis Set"], spans["redis get"]
+	if set == nil || get == nil || len(spans) != 3 {
+		t.Fatal("missing redis spans", spans)
+	}
+	for _, span := range []*exportedSpan{set, get} {
+		if span.Parent.SpanID != parent.SpanContext().SpanID().String() {
+			t.Fatal("redis span must be child of request span", span)
+		}
+	}
+	if set.attribute("db.operation") != "Set" || get.attribute("db.system") != "redis" {
+		t.Fatal("unexpected attributes", set.Attributes, get.Attributes)
+	}
+}
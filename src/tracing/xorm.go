@@ -0,0 +1,39 @@
+package tracing
+
+import (
+	"context"
+	"github.com/go-various/xorm/contexts"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//XormHook 为每条SQL创建span，使用session.Context(ctx)传入请求上下文时作为其子span
+type XormHook struct {
+	//System 数据库类型，写入db.system属性
+	System string
+}
+
+type xormSpanKey struct{}
+
+func (h *XormHook) BeforeProcess(c *contexts.ContextHook) (context.Context, error) {
+	ctx := c.Ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := Start(ctx, "xorm", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", h.System),
+			attribute.String("db.statement", c.SQL),
+		))
+	return context.WithValue(ctx, xormSpanKey{}, span), nil
+}
+
+func (h *XormHook) AfterProcess(c *contexts.ContextHook) error {
+	if c.Ctx == nil {
+		return nil
+	}
+	if span, ok := c.Ctx.Value(xormSpanKey{}).(trace.Span); ok {
+		End(span, c.Err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/36625090/involution/logical"
+	"github.com/36625090/involution/logical/codes"
+	"github.com/gin-gonic/gin"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultBatchSize        = 20
+	defaultBatchConcurrency = 4
+)
+
+var errBatchAborted = errors.New("batch aborted by previous failure")
+
+//isBatch 请求体为JSON数组时视为批量请求
+func isBatch(body []byte) bool {
+	body = bytes.TrimLeft(body, " \t\r\n")
+	return len(body) > 0 && body[0] == '['
+}
+
+//serveBatch 批量请求处理，请求体仅支持JSON，响应按Accept协商编码
+//每个请求信封独立验签、鉴权和处理，按BatchConcurrency并发执行，响应数组与请求顺序一致
+//header X-Batch-Abort=true 时任一请求失败后，尚未开始的请求不再执行并返回CodeBatchAborted
+func (m *Transport) serveBatch(gCtx *gin.Context, body []byte, handle Handle) {
+	var envelopes []json.RawMessage
+	if err := json.Unmarshal(body, &envelopes); err != nil {
+		m.logger.Error("should not bind batch JSON", "path", gCtx.Request.RequestURI, "err", err)
+		m.writeBatchError(gCtx, codes.CodeBindRequestData, err.Error())
+		return
+	}
+
+	size := m.settings.BatchSize
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+	if len(envelopes) == 0 || len(envelopes) > size {
+		m.writeBatchError(gCtx, codes.CodeBindRequestData,
+			fmt.Sprintf("batch size must be between 1 and %d", size))
+		return
+	}
+
+	concurrency := m.settings.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	abort, _ := strconv.ParseBool(gCtx.GetHeader(logical.HeaderBatchAbortKey.String()))
+
+	contexts := make([]*Context, len(envelopes))
+	for i, envelope := range envelopes {
+		contexts[i] = NewContext(gCtx)
+		contexts[i].body = envelope
+		contexts[i].shared = true
+	}
+
+	var failed int32
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, concurrency)
+	for _, ctx := range contexts {
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func(ctx *Context) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			if abort && atomic.LoadInt32(&failed) > 0 {
+				ctx.response.TraceID = ctx.GetTraceID()
+				ctx.WithCode(codes.CodeBatchAborted).WithError(errBatchAborted)
+				return
+			}
+			m.serve(ctx, handle)
+			if ctx.response.Code != codes.CodeSuccess.Int() {
+				atomic.AddInt32(&failed, 1)
+			}
+		}(ctx)
+	}
+	wg.Wait()
+
+	responses := make([]*Response, len(contexts))
+	for i, ctx := range contexts {
+		responses[i] = ctx.response
+	}
+	if version := contexts[0].signKeyVersion; version != "" {
+		gCtx.Header(logical.HeaderSignKeyVersionKey.String(), version)
+	}
+	if version := contexts[0].signVersion; version != "" {
+		gCtx.Header(logical.HeaderSignVersionKey.String(), version)
+	}
+	//批量响应中各请求的状态码不同，始终返回200
+	render(gCtx, 200, responses)
+}
+
+func (m *Transport) writeBatchError(gCtx *gin.Context, code codes.ReturnCode, message string) {
+	render(gCtx, m.HTTPStatus(code.Int()), &Response{
+		Code:      code.Int(),
+		Message:   message,
+		TraceID:   gCtx.GetHeader(logical.HeaderTraceIDKey.String()),
+		Timestamp: time.Now().UnixMilli(),
+	})
+}
@@ -0,0 +1,124 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+)
+
+const (
+	//SignVersion1 原始签名串：按键排序拼接 key+value，跳过空值
+	SignVersion1 = "v1"
+	//SignVersion2 规范化签名串，参考canonicalV2
+	SignVersion2 = "v2"
+)
+
+//canonicalCodec 自行生成签名串的Codec，signer.build优先使用
+type canonicalCodec interface {
+	Codec
+	Canonical() []byte
+}
+
+//canonicalMapper v2签名串使用与v1不同字段值的Codec，如响应的content和pagination
+type canonicalMapper interface {
+	CanonicalMap() map[string]interface{}
+}
+
+//canonicalV2 v2签名串，每行为 key:长度:value，长度为value的字节数，空值同样参与签名
+//依次包含签名版本、请求路径、X-Client-ID，之后为按键排序的信封字段(sign除外)，
+//data和content替换为其规范化JSON的SHA256(body_sha256)，响应的content和pagination使用CanonicalContent
+//响应签名使用对应请求的路径和客户端ID
+type canonicalV2 struct {
+	Codec
+	path     string
+	clientID string
+}
+
+func (c *canonicalV2) Canonical() []byte {
+	var buf bytes.Buffer
+	writeCanonicalField(&buf, "sign_version", SignVersion2)
+	writeCanonicalField(&buf, "path", c.path)
+	writeCanonicalField(&buf, "client_id", c.clientID)
+
+	params := c.Map()
+	if mapper, ok := c.Codec.(canonicalMapper); ok {
+		params = mapper.CanonicalMap()
+	}
+	for _, key := range c.Keys() {
+		if key == "sign" {
+			continue
+		}
+		value := canonicalValue(params[key])
+		if key == "data" || key == "content" {
+			sum := sha256.Sum256([]byte(value))
+			key, value = "body_sha256", hex.EncodeToString(sum[:])
+		}
+		writeCanonicalField(&buf, key, value)
+	}
+	return buf.Bytes()
+}
+
+func writeCanonicalField(buf *bytes.Buffer, key string, value string) {
+	buf.WriteString(key)
+	buf.WriteByte(':')
+	buf.WriteString(strconv.Itoa(len(value)))
+	buf.WriteByte(':')
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+func canonicalValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+//canonical 按签名版本包装需要签名的请求或响应
+func (c *Context) canonical(version string, codec Codec) Codec {
+	if version != SignVersion2 {
+		return codec
+	}
+	return CanonicalV2(codec, c.ctx.Request.URL.Path, c.GetClientID())
+}
+
+//CanonicalV2 使用v2签名串包装请求或响应，供客户端签名和验签使用
+//path 请求路径，clientID 请求头X-Client-ID
+func CanonicalV2(codec Codec, path string, clientID string) Codec {
+	return &canonicalV2{Codec: codec, path: path, clientID: clientID}
+}
+
+//SignVersion 客户端使用的签名版本，sign_versions未配置的客户端使用sign_version，默认v1
+func (s *Settings) SignVersion(clientID string) string {
+	if version, ok := s.SignVersions[clientID]; ok {
+		return version
+	}
+	if s.DefaultSignVersion != "" {
+		return s.DefaultSignVersion
+	}
+	return SignVersion1
+}
+
+func (s *Settings) validateSignVersions() error {
+	versions := map[string]string{"": s.DefaultSignVersion}
+	for client, version := range s.SignVersions {
+		versions[client] = version
+	}
+	for client, version := range versions {
+		switch version {
+		case "", SignVersion1, SignVersion2:
+		default:
+			return fmt.Errorf("unsupported sign version %q for client %q", version, client)
+		}
+	}
+	return nil
+}
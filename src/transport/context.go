@@ -1,9 +1,12 @@
 package transport
 
 import (
+	"context"
+	"encoding/json"
 	"github.com/36625090/involution/logical"
 	"github.com/36625090/involution/logical/codes"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/pkg/errors"
 	"net/http"
 	"strings"
@@ -12,8 +15,35 @@ import (
 
 type Context struct {
 	ctx      *gin.Context
+	body     []byte
 	request  *Request
 	response *Response
+	//signKeyVersion 响应签名使用的密钥版本
+	signKeyVersion string
+	//signVersion 响应签名串的版本
+	signVersion string
+	//stream 客户端请求SSE时的流式输出
+	stream *sseWriter
+	//files multipart请求上传的文件
+	files []*uploadFile
+	//downloadable 是否可以直接输出文件内容，仅普通HTTP请求支持
+	downloadable bool
+	//download 操作返回的下载内容
+	download *downloadStream
+	//shared 批量请求和ServeEnvelope共用请求头，不使用请求头中的幂等键
+	shared bool
+	//reused 响应复用了幂等请求保存的签名响应
+	reused bool
+	//onSigned 响应处理完成后的回调
+	onSigned []func(c *Context)
+	//traceCtx 携带当前请求信封span的上下文
+	traceCtx context.Context
+	//bound 请求由其他协议转换得到，不再绑定，参考ServeRequest
+	bound bool
+	//unsigned 跳过验签和防重放
+	unsigned bool
+	//signData 参与验签的data，为空时使用请求的data
+	signData Data
 }
 
 func NewContext(ctx *gin.Context) *Context {
@@ -41,19 +71,74 @@ func (c *Context) GetClientID() string {
 	return c.ctx.GetHeader(string(logical.HeaderClientIDKey))
 }
 
+//GetSignKeyVersion 客户端请求签名使用的密钥版本
+func (c *Context) GetSignKeyVersion() string {
+	return c.ctx.GetHeader(string(logical.HeaderSignKeyVersionKey))
+}
+
+//GetIdempotencyKey 客户端请求的幂等键，批量请求和WebSocket消息不支持
+func (c *Context) GetIdempotencyKey() string {
+	if c.shared {
+		return ""
+	}
+	return c.ctx.GetHeader(string(logical.HeaderIdempotencyKey))
+}
+
 func (c *Context) ShouldBindJSON() error {
+	if c.body != nil {
+		return binding.JSON.BindBody(c.body, c.request)
+	}
 	return c.ctx.ShouldBindJSON(c.request)
 }
 
+//ShouldBind 按Content-Type解码请求信封并校验，支持JSON XML MessagePack Protobuf及注册的编码
+func (c *Context) ShouldBind() error {
+	body := c.body
+	if body == nil {
+		var err error
+		if body, err = c.ctx.GetRawData(); err != nil {
+			return err
+		}
+	}
+	if err := requestEncoding(c.ctx.ContentType()).Decode(body, c.request); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(c.request)
+}
+
+//signedRequest 参与验签的请求
+func (c *Context) signedRequest() *Request {
+	if c.signData == nil {
+		return c.request
+	}
+	req := *c.request
+	req.Data = c.signData
+	return &req
+}
+
 //Request 获取客户端请求数据
 func (c *Context) Request() *Request {
 	return c.request
 }
 
+//Response 获取返回给客户端的响应，签名前可被修改
+func (c *Context) Response() *Response {
+	return c.response
+}
+
 func (c *Context) RawRequest() *http.Request {
 	return c.ctx.Request
 }
 
+//TraceContext 携带当前请求信封span的上下文，调用后端逻辑及其他服务时使用
+//批量请求中每个请求信封的span不同
+func (c *Context) TraceContext() context.Context {
+	if c.traceCtx != nil {
+		return c.traceCtx
+	}
+	return c.ctx.Request.Context()
+}
+
 //DecodeArgs 解码逻辑请求数据结构
 func (c *Context) DecodeArgs() (*logical.Args, error) {
 
@@ -62,17 +147,31 @@ func (c *Context) DecodeArgs() (*logical.Args, error) {
 		return nil, errors.New("method not supported: " + c.request.Method)
 	}
 
+	data, err := c.request.Data.Payload()
+	if err != nil {
+		return nil, errors.Wrap(err, "decode data")
+	}
+
 	//逻辑请求
 	args := &logical.Args{
 		Backend:    methods[0],
 		Endpoint:   methods[1],
 		Operation:  methods[2],
-		Data:       c.request.Data,
+		Version:    c.request.Version,
+		Data:       json.RawMessage(data),
 		Headers:    map[string][]string{},
 		Connection: &logical.Connection{RemoteAddr: c.ctx.Request.RemoteAddr, UserAgent: c.ctx.Request.UserAgent()},
 	}
 
 	args.SetTraceID(c.GetTraceID())
+	args.SetTraceHeaders(c.ctx.Request.Header)
+	args.SetClientID(c.GetClientID())
+	if c.stream != nil {
+		args.Stream = c.stream
+	}
+	for _, file := range c.files {
+		args.Files = append(args.Files, file.File)
+	}
 
 	return args, nil
 
@@ -107,6 +206,39 @@ func (c *Context) WithSign(sign string) *Context {
 	return c
 }
 
-func (c *Context) write() {
-	c.ctx.JSON(200, c.response)
+//Stream 客户端请求SSE时返回流式输出，否则返回nil
+func (c *Context) Stream() logical.StreamWriter {
+	if c.stream == nil {
+		return nil
+	}
+	return c.stream
+}
+
+//write 输出响应，status为HTTP状态码，SSE已开始输出时忽略
+func (c *Context) write(status int) {
+	if c.signKeyVersion != "" && !c.ctx.Writer.Written() {
+		c.ctx.Header(logical.HeaderSignKeyVersionKey.String(), c.signKeyVersion)
+	}
+	if c.signVersion != "" && !c.ctx.Writer.Written() {
+		c.ctx.Header(logical.HeaderSignVersionKey.String(), c.signVersion)
+	}
+	if c.download != nil {
+		if c.response.Code == codes.CodeSuccess.Int() {
+			c.writeDownload()
+			return
+		}
+		c.removeDownload()
+	}
+	if c.stream != nil {
+		//未输出过事件时按状态码返回完整响应
+		if c.stream.id == 0 && status != http.StatusOK {
+			c.ctx.Writer.Header().Del("Content-Type")
+			render(c.ctx, status, c.response)
+			return
+		}
+		//最后一帧为签名后的完整响应
+		_ = c.stream.write(StreamEventResponse, c.response)
+		return
+	}
+	render(c.ctx, status, c.response)
 }
\ No newline at end of file
@@ -0,0 +1,108 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+)
+
+//Data 请求数据，可以是JSON字符串(兼容旧客户端的二次编码)或原生JSON对象、数组
+//签名规范化规则:
+//  JSON字符串: 使用字符串本身
+//  对象或数组: 键按字典序排序、无空白、不转义HTML字符、数字保持原样的紧凑JSON
+type Data []byte
+
+var errInvalidData = errors.New("data must be a JSON string, object or array")
+
+//NewData 使用原生JSON构造请求数据
+func NewData(v interface{}) (Data, error) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Data(bs), nil
+}
+
+func (d Data) MarshalJSON() ([]byte, error) {
+	if len(d) == 0 {
+		return []byte("null"), nil
+	}
+	return d, nil
+}
+
+func (d *Data) UnmarshalJSON(bs []byte) error {
+	trimmed := bytes.TrimSpace(bs)
+	if len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		*d = nil
+		return nil
+	}
+	switch trimmed[0] {
+	case '"', '{', '[':
+	default:
+		return errInvalidData
+	}
+	*d = append((*d)[0:0], trimmed...)
+	return nil
+}
+
+//UnmarshalXML XML元素内容为JSON对象或数组时按原生JSON处理，否则视为字符串
+func (d *Data) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var s string
+	if err := dec.DecodeElement(&s, &start); err != nil {
+		return err
+	}
+	trimmed := bytes.TrimSpace([]byte(s))
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		*d = trimmed
+		return nil
+	}
+	bs, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	*d = bs
+	return nil
+}
+
+//IsString 是否为二次编码的JSON字符串
+func (d Data) IsString() bool {
+	return len(d) > 0 && d[0] == '"'
+}
+
+//Payload 返回业务数据的JSON，JSON字符串会被解码一次
+func (d Data) Payload() ([]byte, error) {
+	if !d.IsString() {
+		return d, nil
+	}
+	var s string
+	if err := json.Unmarshal(d, &s); err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+//Canonical 返回参与签名的规范化字符串
+func (d Data) Canonical() (string, error) {
+	if len(d) == 0 {
+		return "", nil
+	}
+	if d.IsString() {
+		bs, err := d.Payload()
+		return string(bs), err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(d))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(buf.Bytes(), "\n")), nil
+}
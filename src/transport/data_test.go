@@ -0,0 +1,39 @@
+package transport
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestData_Canonical(t *testing.T) {
+	cases := []struct {
+		body      string
+		canonical string
+		payload   string
+	}{
+		{`{"data":"{\"b\":1,\"a\":\"x\"}"}`, `{"b":1,"a":"x"}`, `{"b":1,"a":"x"}`},
+		{`{"data":{"b":1, "a":"<x>", "c":[1.50, {"z":null,"y":true}]}}`,
+			`{"a":"<x>","b":1,"c":[1.50,{"y":true,"z":null}]}`,
+			`{"b":1, "a":"<x>", "c":[1.50, {"z":null,"y":true}]}`},
+		{`{"data":[3,2,1]}`, `[3,2,1]`, `[3,2,1]`},
+	}
+	for _, c := range cases {
+		var req Request
+		if err := json.Unmarshal([]byte(c.body), &req); err != nil {
+			t.Fatal(c.body, err)
+		}
+		canonical, err := req.Data.Canonical()
+		if err != nil || canonical != c.canonical {
+			t.Fatal(c.body, canonical, err)
+		}
+		payload, err := req.Data.Payload()
+		if err != nil || string(payload) != c.payload {
+			t.Fatal(c.body, string(payload), err)
+		}
+	}
+
+	var req Request
+	if err := json.Unmarshal([]byte(`{"data":123}`), &req); err == nil {
+		t.Fatal("numeric data accepted")
+	}
+}
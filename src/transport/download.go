@@ -0,0 +1,102 @@
+package transport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/36625090/involution/logical"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+var errDownloadUnsupported = errors.New("download response is only supported on plain HTTP requests")
+var errDownloadEncrypted = errors.New("download response cannot be encrypted")
+
+//DownloadInfo 下载响应中参与签名的content
+//下载响应的签名对象为 Response{code:0, message:"", content:DownloadInfo, trace_id, timestamp}，
+//客户端按响应头 X-Trace-ID、X-Timestamp、Content-Type、Content-Disposition的filename、实际接收的字节数
+//及 HTTP trailer X-Content-SHA256 重建后验证 HTTP trailer X-Sign
+//文件内容直接流式输出，摘要和签名在输出完成后才能得到，因此通过trailer返回，浏览器的fetch无法读取trailer
+type DownloadInfo struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+}
+
+//downloadStream 待输出的下载内容，输出完成后按摘要签名
+type downloadStream struct {
+	info        *DownloadInfo
+	disposition string
+	body        io.ReadCloser
+	//sign 签名content为info的响应
+	sign func(resp *Response) (string, error)
+}
+
+//prepareDownload 校验下载响应并以DownloadInfo替换响应content，文件内容在writeDownload中输出
+func (m *Transport) prepareDownload(ctx *Context, encrypt string, download *logical.Download) error {
+	if !ctx.downloadable {
+		download.Close()
+		return errDownloadUnsupported
+	}
+	if encrypt != "" {
+		download.Close()
+		return errDownloadEncrypted
+	}
+	if download.Body == nil {
+		return errors.New("download body is nil")
+	}
+
+	info := &DownloadInfo{
+		Filename:    download.Filename,
+		ContentType: download.ContentType,
+	}
+	if info.ContentType == "" {
+		info.ContentType = "application/octet-stream"
+	}
+	ctx.download = &downloadStream{info: info, disposition: download.Disposition(), body: download.Body}
+	ctx.response.Content = info
+	return nil
+}
+
+//writeDownload 流式输出文件内容，trace-id和时间戳放在响应头，内容摘要和签名放在trailer
+//输出中途失败时不返回签名，客户端验签失败
+func (c *Context) writeDownload() {
+	defer c.download.body.Close()
+
+	info := c.download.info
+	header := c.ctx.Writer.Header()
+	header.Set("Content-Type", info.ContentType)
+	header.Set(logical.HeaderTraceIDKey.String(), c.response.TraceID)
+	header.Set(logical.HeaderTimestampKey.String(), strconv.FormatInt(c.response.Timestamp, 10))
+	if info.Filename != "" {
+		header.Set("Content-Disposition", mime.FormatMediaType(c.download.disposition, map[string]string{"filename": info.Filename}))
+	}
+	header.Set("Trailer", logical.HeaderContentSHA256Key.String()+", "+logical.HeaderSignKey.String())
+	c.ctx.Status(http.StatusOK)
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(c.ctx.Writer, hash), c.download.body)
+	if err != nil {
+		c.ctx.Error(err)
+		return
+	}
+	info.Size = size
+	info.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	sign, err := c.download.sign(c.response)
+	if err != nil {
+		c.ctx.Error(err)
+		return
+	}
+	c.WithSign(sign)
+	header.Set(logical.HeaderContentSHA256Key.String(), info.SHA256)
+	header.Set(logical.HeaderSignKey.String(), sign)
+}
+
+func (c *Context) removeDownload() {
+	if c.download != nil {
+		c.download.body.Close()
+	}
+}
@@ -0,0 +1,133 @@
+package transport
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	MIMEJSON     = "application/json"
+	MIMEXML      = "application/xml"
+	MIMEXML2     = "text/xml"
+	MIMEMsgPack  = "application/msgpack"
+	MIMEMsgPack2 = "application/x-msgpack"
+	MIMEProtobuf = "application/x-protobuf"
+)
+
+//Encoding 请求信封和响应的编解码接口，按媒体类型协商
+//签名只依赖Codec的规范化结果，与传输编码无关
+type Encoding interface {
+	//ContentType 响应使用的Content-Type
+	ContentType() string
+	Decode(body []byte, req *Request) error
+	Encode(resp interface{}) ([]byte, error)
+}
+
+var encodings = struct {
+	sync.RWMutex
+	m map[string]Encoding
+}{m: map[string]Encoding{}}
+
+func init() {
+	RegisterEncoding(jsonEncoding{}, MIMEJSON)
+	RegisterEncoding(xmlEncoding{}, MIMEXML, MIMEXML2)
+	RegisterEncoding(newMsgPackEncoding(), MIMEMsgPack, MIMEMsgPack2)
+	RegisterEncoding(protobufEncoding{}, MIMEProtobuf)
+}
+
+//RegisterEncoding 注册编解码，mediaTypes为空时使用ContentType
+func RegisterEncoding(encoding Encoding, mediaTypes ...string) {
+	if len(mediaTypes) == 0 {
+		mediaTypes = []string{encoding.ContentType()}
+	}
+	encodings.Lock()
+	defer encodings.Unlock()
+	for _, mediaType := range mediaTypes {
+		encodings.m[strings.ToLower(mediaType)] = encoding
+	}
+}
+
+func lookupEncoding(mediaType string) (Encoding, bool) {
+	encodings.RLock()
+	defer encodings.RUnlock()
+	e, ok := encodings.m[strings.ToLower(mediaType)]
+	return e, ok
+}
+
+//requestEncoding 根据Content-Type选择请求编码，未知类型按JSON处理
+func requestEncoding(contentType string) Encoding {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if e, ok := lookupEncoding(mediaType); ok {
+			return e
+		}
+	}
+	return jsonEncoding{}
+}
+
+//responseEncoding 根据Accept选择响应编码，未指定或无法满足时与请求编码一致
+func responseEncoding(accept string, fallback Encoding) Encoding {
+	type candidate struct {
+		mediaType string
+		q         float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		candidates = append(candidates, candidate{mediaType: mediaType, q: q})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	for _, c := range candidates {
+		if c.q <= 0 {
+			continue
+		}
+		if c.mediaType == "*/*" {
+			return fallback
+		}
+		if e, ok := lookupEncoding(c.mediaType); ok {
+			return e
+		}
+	}
+	return fallback
+}
+
+//render 按协商的编码写出响应，编码失败时回退为JSON
+func render(gCtx *gin.Context, status int, resp interface{}) {
+	reqEncoding := requestEncoding(gCtx.ContentType())
+	encoding := responseEncoding(gCtx.GetHeader("Accept"), reqEncoding)
+	bs, err := encoding.Encode(resp)
+	if err != nil {
+		gCtx.JSON(status, resp)
+		return
+	}
+	gCtx.Data(status, encoding.ContentType(), bs)
+}
+
+type jsonEncoding struct{}
+
+func (jsonEncoding) ContentType() string {
+	return MIMEJSON + "; charset=utf-8"
+}
+
+func (jsonEncoding) Decode(body []byte, req *Request) error {
+	return json.Unmarshal(body, req)
+}
+
+func (jsonEncoding) Encode(resp interface{}) ([]byte, error) {
+	return json.Marshal(resp)
+}
@@ -0,0 +1,66 @@
+package transport
+
+import (
+	"encoding/json"
+	"github.com/ugorji/go/codec"
+	"reflect"
+	"strconv"
+)
+
+//msgPackEncoding MessagePack编解码，字段名与JSON tag一致
+type msgPackEncoding struct {
+	handle *codec.MsgpackHandle
+}
+
+func newMsgPackEncoding() Encoding {
+	handle := new(codec.MsgpackHandle)
+	handle.RawToString = true
+	handle.WriteExt = true
+	handle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	return &msgPackEncoding{handle: handle}
+}
+
+func (m *msgPackEncoding) ContentType() string {
+	return MIMEMsgPack
+}
+
+//Decode 先解码为通用结构再转为JSON，data字段可为字符串或原生map、数组
+func (m *msgPackEncoding) Decode(body []byte, req *Request) error {
+	var v map[string]interface{}
+	if err := codec.NewDecoderBytes(body, m.handle).Decode(&v); err != nil {
+		return err
+	}
+	//bin类型的data按字符串处理
+	if data, ok := v["data"].([]byte); ok {
+		v["data"] = string(data)
+	}
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, req)
+}
+
+//Encode 先通过JSON转换为通用结构，content中的值与JSON响应一致(如时间为字符串)，整数以外的数字为float64
+//整数保持原值，与CanonicalContent中数字的原样表示一致
+func (m *msgPackEncoding) Encode(resp interface{}) ([]byte, error) {
+	value, err := jsonValue(resp)
+	if err != nil {
+		return nil, err
+	}
+	value, err = convertNumbers(value, func(n json.Number) (interface{}, error) {
+		if i, err := n.Int64(); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+			return u, nil
+		}
+		return n.Float64()
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out []byte
+	err = codec.NewEncoderBytes(&out, m.handle).Encode(value)
+	return out, err
+}
@@ -0,0 +1,102 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+//protobufEncoding Protobuf编解码
+//请求和响应信封均为google.protobuf.Value，结构与JSON信封一致，客户端无需额外的proto定义
+type protobufEncoding struct{}
+
+func (protobufEncoding) ContentType() string {
+	return MIMEProtobuf
+}
+
+func (protobufEncoding) Decode(body []byte, req *Request) error {
+	value := new(structpb.Value)
+	if err := proto.Unmarshal(body, value); err != nil {
+		return err
+	}
+	bs, err := json.Marshal(fromProtoValue(value))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, req)
+}
+
+func (protobufEncoding) Encode(resp interface{}) ([]byte, error) {
+	value, err := jsonValue(resp)
+	if err != nil {
+		return nil, err
+	}
+	pv, err := toProtoValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(pv)
+}
+
+func toProtoValue(v interface{}) (*structpb.Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return &structpb.Value{Kind: &structpb.Value_NullValue{}}, nil
+	case bool:
+		return &structpb.Value{Kind: &structpb.Value_BoolValue{BoolValue: v}}, nil
+	case string:
+		return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: v}}, nil
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return &structpb.Value{Kind: &structpb.Value_NumberValue{NumberValue: f}}, nil
+	case []interface{}:
+		list := &structpb.ListValue{Values: make([]*structpb.Value, 0, len(v))}
+		for _, item := range v {
+			pv, err := toProtoValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list.Values = append(list.Values, pv)
+		}
+		return &structpb.Value{Kind: &structpb.Value_ListValue{ListValue: list}}, nil
+	case map[string]interface{}:
+		s := &structpb.Struct{Fields: make(map[string]*structpb.Value, len(v))}
+		for key, item := range v {
+			pv, err := toProtoValue(item)
+			if err != nil {
+				return nil, err
+			}
+			s.Fields[key] = pv
+		}
+		return &structpb.Value{Kind: &structpb.Value_StructValue{StructValue: s}}, nil
+	}
+	return nil, fmt.Errorf("unsupported protobuf value type %T", v)
+}
+
+func fromProtoValue(v *structpb.Value) interface{} {
+	switch kind := v.GetKind().(type) {
+	case *structpb.Value_BoolValue:
+		return kind.BoolValue
+	case *structpb.Value_StringValue:
+		return kind.StringValue
+	case *structpb.Value_NumberValue:
+		return kind.NumberValue
+	case *structpb.Value_ListValue:
+		list := make([]interface{}, 0, len(kind.ListValue.GetValues()))
+		for _, item := range kind.ListValue.GetValues() {
+			list = append(list, fromProtoValue(item))
+		}
+		return list
+	case *structpb.Value_StructValue:
+		m := make(map[string]interface{}, len(kind.StructValue.GetFields()))
+		for key, item := range kind.StructValue.GetFields() {
+			m[key] = fromProtoValue(item)
+		}
+		return m
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"github.com/36625090/involution/logical"
+	"github.com/gin-gonic/gin"
+	"github.com/hashicorp/go-hclog"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEncoding_Negotiation(t *testing.T) {
+	tr := newTestTransport(t, &Settings{DefaultPolicy: SignPolicyAllow})
+	req := &Request{Method: "account.user.home", Data: Data(`{}`), Timestamp: 1, Version: "1.0", Sign: "-", SignType: SignTypeMD5}
+
+	for _, mediaType := range []string{MIMEJSON, MIMEXML, MIMEMsgPack, MIMEProtobuf} {
+		enc, _ := lookupEncoding(mediaType)
+		var body []byte
+		var err error
+		switch mediaType {
+		case MIMEXML:
+			body = []byte(`<request><method>account.user.home</method><data>{}</data><timestamp>1</timestamp>` +
+				`<version>1.0</version><sign>-</sign><sign_type>md5</sign_type></request>`)
+		default:
+			body, err = enc.Encode(req)
+		}
+		if err != nil {
+			t.Fatal(mediaType, err)
+		}
+
+		httpReq := httptest.NewRequest(http.MethodPost, "/api", bytes.NewReader(body))
+		httpReq.Header.Set("Content-Type", mediaType)
+		httpReq.Header.Set("Accept", "text/html;q=0.9, "+mediaType)
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, httpReq)
+
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), enc.ContentType()) {
+			t.Fatal(mediaType, "unexpected content type", w.Header().Get("Content-Type"))
+		}
+		if !bytes.Contains(w.Body.Bytes(), []byte("account.user.home")) {
+			t.Fatal(mediaType, "unexpected response", w.Body.String())
+		}
+	}
+}
+
+func TestEncoding_ResponseEncoding(t *testing.T) {
+	fallback := jsonEncoding{}
+	cases := map[string]string{
+		"":                                  MIMEJSON,
+		"*/*":                               MIMEJSON,
+		"application/xml":                   MIMEXML,
+		"application/xml;q=0.5, text/xml":   MIMEXML,
+		"application/x-msgpack":             MIMEMsgPack,
+		"application/x-protobuf;q=0, */*":   MIMEJSON,
+		"text/html, application/x-protobuf": MIMEProtobuf,
+	}
+	for accept, expected := range cases {
+		enc := responseEncoding(accept, fallback)
+		if !strings.HasPrefix(enc.ContentType(), expected) {
+			t.Fatal(accept, enc.ContentType())
+		}
+	}
+}
+
+//TestEncoding_ResponseSignature v2签名时各编码的客户端按解码得到的通用结构验签
+func TestEncoding_ResponseSignature(t *testing.T) {
+	settings := &Settings{
+		SignType:      SignTypeMD5,
+		SignKeys:      map[string]string{GlobalSignKey: "521004524ef99ad954ad93c3f91c82fd", "user1": "d41d8cd98f00b204e9800998ecf8427e"},
+		DefaultPolicy: SignPolicyDeny,
+		SignVersions:  map[string]string{"user2": SignVersion2},
+	}
+	settings.SignKeys["user2"] = settings.SignKeys["user1"]
+	tr, err := NewTransport(gin.New(), settings, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	type item struct {
+		Name  string  `json:"name"`
+		Price float64 `json:"price"`
+	}
+	tr.AddHandle("/api", logical.HttpMethodPOST, func(c *Context) error {
+		c.WithContent(map[string]interface{}{
+			"title": "<b>a & b</b>",
+			"items": []item{{Name: "x", Price: 1.5}, {Name: "y", Price: 2}},
+			"big":   int64(1 << 53),
+			"ok":    true,
+			"none":  nil,
+		})
+		c.WithPagination(&logical.Pagination{Page: 1, Size: 20, Total: 2})
+		return nil
+	})
+	signer := NewMD5Signer(settings)
+
+	msgpack := newMsgPackEncoding().(*msgPackEncoding)
+	decoders := map[string]func(body []byte) (*Response, error){
+		//JSON的content和pagination保留原始JSON，v1签名使用服务端的JSON编码
+		MIMEJSON: func(body []byte) (*Response, error) {
+			var v struct {
+				Response
+				Content    json.RawMessage `json:"content"`
+				Pagination json.RawMessage `json:"pagination"`
+			}
+			if err := json.Unmarshal(body, &v); err != nil {
+				return nil, err
+			}
+			resp := v.Response
+			resp.Content, resp.Pagination = v.Content, v.Pagination
+			return &resp, nil
+		},
+		//XML的content和pagination为规范化文本
+		MIMEXML: func(body []byte) (*Response, error) {
+			var v struct {
+				Code       int    `xml:"code"`
+				Message    string `xml:"message"`
+				Content    string `xml:"content"`
+				Pagination string `xml:"pagination"`
+				TraceID    string `xml:"trace_id"`
+				Timestamp  int64  `xml:"timestamp"`
+				Sign       string `xml:"sign"`
+			}
+			if err := xml.Unmarshal(body, &v); err != nil {
+				return nil, err
+			}
+			return &Response{Code: v.Code, Message: v.Message, Content: v.Content, Pagination: v.Pagination,
+				TraceID: v.TraceID, Timestamp: v.Timestamp, Sign: v.Sign}, nil
+		},
+		MIMEMsgPack: func(body []byte) (*Response, error) {
+			var v map[string]interface{}
+			if err := codec.NewDecoderBytes(body, msgpack.handle).Decode(&v); err != nil {
+				return nil, err
+			}
+			return responseFromValue(v)
+		},
+		MIMEProtobuf: func(body []byte) (*Response, error) {
+			value := new(structpb.Value)
+			if err := proto.Unmarshal(body, value); err != nil {
+				return nil, err
+			}
+			return responseFromValue(fromProtoValue(value))
+		},
+	}
+
+	for _, client := range []string{"user1", "user2"} {
+		for mediaType, decode := range decoders {
+			//v1签名的content为JSON编码，只保证JSON客户端可以验签
+			if client == "user1" && mediaType != MIMEJSON {
+				continue
+			}
+			req := Request{Method: "account.user.home", Data: Data(`{}`), Timestamp: 1, Version: "1.0", SignType: SignTypeMD5}
+			var reqCodec Codec = &req
+			if client == "user2" {
+				reqCodec = CanonicalV2(&req, "/api", client)
+			}
+			req.Sign, _ = signer.Sign(KeyID{ID: client}, reqCodec)
+			body, _ := json.Marshal(req)
+			r := httptest.NewRequest(http.MethodPost, "/api", bytes.NewReader(body))
+			r.Header.Set("Content-Type", MIMEJSON)
+			r.Header.Set("Accept", mediaType)
+			r.Header.Set(logical.HeaderClientIDKey.String(), client)
+			w := httptest.NewRecorder()
+			tr.ServeHTTP(w, r)
+
+			resp, err := decode(w.Body.Bytes())
+			if err != nil || resp.Code != 0 {
+				t.Fatal(client, mediaType, "unexpected response", err, w.Body.String())
+			}
+			sign := resp.Sign
+			resp.Sign = ""
+			var respCodec Codec = resp
+			if client == "user2" {
+				respCodec = CanonicalV2(resp, "/api", client)
+			}
+			if err := signer.Verify(KeyID{ID: GlobalSignKey}, sign, respCodec); err != nil {
+				content, _ := CanonicalContent(resp.Content)
+				t.Fatal(client, mediaType, "response signature", err, content)
+			}
+		}
+	}
+}
+
+//responseFromValue MessagePack和Protobuf解码得到的通用结构转换为响应
+func responseFromValue(v interface{}) (*Response, error) {
+	m, _ := v.(map[string]interface{})
+	resp := &Response{Content: m["content"], Pagination: m["pagination"]}
+	resp.Code = int(integer(m["code"]))
+	resp.Timestamp = integer(m["timestamp"])
+	resp.Message, _ = m["message"].(string)
+	resp.TraceID, _ = m["trace_id"].(string)
+	resp.Sign, _ = m["sign"].(string)
+	return resp, nil
+}
+
+func integer(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case uint64:
+		return int64(n)
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
+
+//TestResponse_Map v1签名保持content的JSON编码，v2签名使用规范化字符串
+func TestResponse_Map(t *testing.T) {
+	resp := &Response{
+		Content:    map[string]interface{}{"title": "a<b", "price": json.Number("1.50")},
+		Pagination: "page",
+	}
+	v1, v2 := resp.Map(), resp.CanonicalMap()
+	if v1["content"] != `{"price":1.50,"title":"a\u003cb"}` || v1["pagination"] != `"page"` {
+		t.Fatal("unexpected v1 content", v1["content"], v1["pagination"])
+	}
+	if v2["content"] != `{"price":1.50,"title":"a<b"}` || v2["pagination"] != "page" {
+		t.Fatal("unexpected v2 content", v2["content"], v2["pagination"])
+	}
+	if data, _ := Data(`{"title":"a<b","price":1.50}`).Canonical(); data != v2["content"] {
+		t.Fatal("response content and request data canonicalize differently", data, v2["content"])
+	}
+}
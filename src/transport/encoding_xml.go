@@ -0,0 +1,142 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+)
+
+//xmlEncoding XML编解码
+//响应按JSON结构转换为元素树：对象的键为子元素，数组元素为重复的<item>，根元素为<response>或<responses>
+//响应的content和pagination为CanonicalContent文本，与请求的data一致，v2签名的客户端可直接用于验签
+type xmlEncoding struct{}
+
+func (xmlEncoding) ContentType() string {
+	return MIMEXML + "; charset=utf-8"
+}
+
+func (xmlEncoding) Decode(body []byte, req *Request) error {
+	return xml.Unmarshal(body, req)
+}
+
+func (xmlEncoding) Encode(resp interface{}) ([]byte, error) {
+	value, err := jsonValue(resp)
+	if err != nil {
+		return nil, err
+	}
+	if err := canonicalXMLContent(value); err != nil {
+		return nil, err
+	}
+	root := "response"
+	if _, ok := value.([]interface{}); ok {
+		root = "responses"
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	if err := encodeXMLElement(enc, xml.StartElement{Name: xml.Name{Local: root}}, value); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//canonicalXMLContent 将响应或批量响应中的content和pagination替换为规范化文本
+//XML无法区分数字、布尔和字符串，规范化文本保证客户端重新生成的签名串与服务端一致
+func canonicalXMLContent(value interface{}) error {
+	items := []interface{}{value}
+	if list, ok := value.([]interface{}); ok {
+		items = list
+	}
+	for _, item := range items {
+		resp, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range []string{"content", "pagination"} {
+			if resp[key] == nil {
+				continue
+			}
+			text, err := canonicalJSON(resp[key])
+			if err != nil {
+				return err
+			}
+			resp[key] = text
+		}
+	}
+	return nil
+}
+
+//jsonValue 通过JSON转换为通用结构，保证各编码的字段名与JSON一致
+func jsonValue(v interface{}) (interface{}, error) {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func encodeXMLElement(enc *xml.Encoder, start xml.StartElement, value interface{}) error {
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := xml.StartElement{Name: xml.Name{Local: key}}
+			if !isXMLName(key) {
+				child = xml.StartElement{
+					Name: xml.Name{Local: "entry"},
+					Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}},
+				}
+			}
+			if err := encodeXMLElement(enc, child, v[key]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := encodeXMLElement(enc, xml.StartElement{Name: xml.Name{Local: "item"}}, item); err != nil {
+				return err
+			}
+		}
+	default:
+		if err := enc.EncodeToken(xml.CharData(fmt.Sprintf("%v", v))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+func isXMLName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		letter := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		if i == 0 && !letter {
+			return false
+		}
+		if !letter && !(r >= '0' && r <= '9') && r != '-' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
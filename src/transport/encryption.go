@@ -0,0 +1,227 @@
+package transport
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	//EncryptRSA 数据密钥使用RSA-OAEP(SHA256)包装：请求使用服务端公钥，响应使用客户端公钥(public_keys)
+	EncryptRSA = "rsa-aes-gcm"
+	//EncryptPSK 数据密钥使用客户端预共享密钥以AES-GCM包装
+	EncryptPSK = "psk-aes-gcm"
+)
+
+var errEncryptionRequired = errors.New("encrypted request data is required")
+var errUnsupportedEncrypt = errors.New("unsupported encrypt mode")
+
+//EncryptionSettings 端到端数据加密配置
+//请求的data和响应的content使用每次请求随机生成的AES-256-GCM密钥加密，密文为base64(nonce|ciphertext)
+//encryption {
+//  required = false
+//  private_key = "MIIEvQIBADANBgkqhkiG9w0BAQEFAASC..."
+//  pre_shared_keys {
+//    user1 = "base64(32字节密钥)"
+//  }
+//}
+type EncryptionSettings struct {
+	//Required 为true时拒绝未加密的请求
+	Required bool `json:"required" hcl:"required"`
+	//PrivateKey 服务端RSA私钥 base64(PKCS8 DER)或PEM，用于解包请求的数据密钥
+	PrivateKey string `json:"-" hcl:"private_key"`
+	//PreSharedKeys 客户端预共享密钥 X-Client-ID => base64(32字节)
+	PreSharedKeys map[string]string `json:"-" hcl:"pre_shared_keys"`
+}
+
+//encryptor 请求解密和响应加密
+type encryptor struct {
+	settings   *EncryptionSettings
+	privateKey *rsa.PrivateKey
+	publicKeys map[string]*rsa.PublicKey
+	psk        map[string][]byte
+}
+
+func newEncryptor(settings *Settings) (*encryptor, error) {
+	if settings.Encryption == nil {
+		return nil, nil
+	}
+	m := &encryptor{
+		settings:   settings.Encryption,
+		publicKeys: map[string]*rsa.PublicKey{},
+		psk:        map[string][]byte{},
+	}
+	if settings.Encryption.PrivateKey != "" {
+		pri, err := ParsePrivateKey(settings.Encryption.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("parse encryption private key: %v", err)
+		}
+		var ok bool
+		if m.privateKey, ok = pri.(*rsa.PrivateKey); !ok {
+			return nil, errors.New("encryption private key must be RSA")
+		}
+	}
+	for clientId, encoded := range settings.PublicKeys {
+		pub, err := ParsePublicKey(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("parse public key of %s: %v", clientId, err)
+		}
+		if rsaPub, ok := pub.(*rsa.PublicKey); ok {
+			m.publicKeys[clientId] = rsaPub
+		}
+	}
+	for clientId, encoded := range settings.Encryption.PreSharedKeys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("pre-shared key of %s must be base64 encoded 32 bytes", clientId)
+		}
+		m.psk[clientId] = key
+	}
+	return m, nil
+}
+
+//decryptRequest 解密请求data，需在验签之后、DecodeArgs之前调用
+func (m *encryptor) decryptRequest(clientId string, req *Request) error {
+	if req.Encrypt == "" {
+		if m.settings.Required {
+			return errEncryptionRequired
+		}
+		return nil
+	}
+
+	key, err := m.unwrapKey(clientId, req.Encrypt, req.EncryptedKey)
+	if err != nil {
+		return err
+	}
+
+	var ciphertext string
+	if err := json.Unmarshal(req.Data, &ciphertext); err != nil {
+		return errors.New("encrypted data must be a base64 string")
+	}
+	plaintext, err := decryptString(key, ciphertext)
+	if err != nil {
+		return fmt.Errorf("decrypt data: %v", err)
+	}
+
+	var data Data
+	if err := data.UnmarshalJSON(plaintext); err != nil {
+		return err
+	}
+	req.Data = data
+	return nil
+}
+
+//encryptResponse 使用新的数据密钥加密响应content，需在签名之前调用
+func (m *encryptor) encryptResponse(clientId, mode string, resp *Response) error {
+	if mode == "" || resp.Content == nil {
+		return nil
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	wrapped, err := m.wrapKey(clientId, mode, key)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := json.Marshal(resp.Content)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := encryptString(key, plaintext)
+	if err != nil {
+		return err
+	}
+
+	resp.Content = ciphertext
+	resp.Encrypt = mode
+	resp.EncryptedKey = wrapped
+	return nil
+}
+
+func (m *encryptor) unwrapKey(clientId, mode, wrapped string) ([]byte, error) {
+	switch mode {
+	case EncryptRSA:
+		if m.privateKey == nil {
+			return nil, errors.New("encryption private key not configured")
+		}
+		bs, err := base64.StdEncoding.DecodeString(wrapped)
+		if err != nil {
+			return nil, err
+		}
+		return rsa.DecryptOAEP(sha256.New(), rand.Reader, m.privateKey, bs, nil)
+	case EncryptPSK:
+		psk, ok := m.psk[clientId]
+		if !ok {
+			return nil, errors.New("pre-shared key not found: " + clientId)
+		}
+		return decryptString(psk, wrapped)
+	}
+	return nil, fmt.Errorf("%s: %s", errUnsupportedEncrypt, mode)
+}
+
+func (m *encryptor) wrapKey(clientId, mode string, key []byte) (string, error) {
+	switch mode {
+	case EncryptRSA:
+		pub, ok := m.publicKeys[clientId]
+		if !ok {
+			return "", errors.New("client RSA public key not found: " + clientId)
+		}
+		bs, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, key, nil)
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(bs), nil
+	case EncryptPSK:
+		psk, ok := m.psk[clientId]
+		if !ok {
+			return "", errors.New("pre-shared key not found: " + clientId)
+		}
+		return encryptString(psk, key)
+	}
+	return "", fmt.Errorf("%s: %s", errUnsupportedEncrypt, mode)
+}
+
+//encryptString AES-GCM加密，返回base64(nonce|ciphertext)
+func encryptString(key, plaintext []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, plaintext, nil)), nil
+}
+
+func decryptString(key []byte, encoded string) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(bs) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, bs[:gcm.NonceSize()], bs[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,88 @@
+package transport
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+func TestEncryptor(t *testing.T) {
+	serverKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pri, _ := x509.MarshalPKCS8PrivateKey(serverKey)
+	psk := make([]byte, 32)
+	rand.Read(psk)
+
+	settings := &Settings{
+		PublicKeys: map[string]string{
+			"user1": base64.StdEncoding.EncodeToString(x509.MarshalPKCS1PublicKey(&clientKey.PublicKey)),
+		},
+		Encryption: &EncryptionSettings{
+			Required:      true,
+			PrivateKey:    base64.StdEncoding.EncodeToString(pri),
+			PreSharedKeys: map[string]string{"user1": base64.StdEncoding.EncodeToString(psk)},
+		},
+	}
+	m, err := newEncryptor(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//客户端加密请求
+	key := make([]byte, 32)
+	rand.Read(key)
+	ciphertext, _ := encryptString(key, []byte(`{"mobile":"13800000000"}`))
+	data, _ := json.Marshal(ciphertext)
+
+	wrappedRSA, _ := rsa.EncryptOAEP(sha256.New(), rand.Reader, &serverKey.PublicKey, key, nil)
+	wrappedPSK, _ := encryptString(psk, key)
+	wrapped := map[string]string{
+		EncryptRSA: base64.StdEncoding.EncodeToString(wrappedRSA),
+		EncryptPSK: wrappedPSK,
+	}
+
+	for mode, encryptedKey := range wrapped {
+		req := &Request{Data: data, Encrypt: mode, EncryptedKey: encryptedKey}
+		if err := m.decryptRequest("user1", req); err != nil {
+			t.Fatal(mode, err)
+		}
+		if string(req.Data) != `{"mobile":"13800000000"}` {
+			t.Fatal(mode, string(req.Data))
+		}
+
+		resp := &Response{Content: map[string]string{"id": "1"}}
+		if err := m.encryptResponse("user1", mode, resp); err != nil {
+			t.Fatal(mode, err)
+		}
+
+		//客户端解密响应
+		var respKey []byte
+		if mode == EncryptRSA {
+			bs, _ := base64.StdEncoding.DecodeString(resp.EncryptedKey)
+			respKey, err = rsa.DecryptOAEP(sha256.New(), rand.Reader, clientKey, bs, nil)
+		} else {
+			respKey, err = decryptString(psk, resp.EncryptedKey)
+		}
+		if err != nil {
+			t.Fatal(mode, err)
+		}
+		plaintext, err := decryptString(respKey, resp.Content.(string))
+		if err != nil || string(plaintext) != `{"id":"1"}` {
+			t.Fatal(mode, string(plaintext), err)
+		}
+	}
+
+	if err := m.decryptRequest("user1", &Request{Data: Data(`{}`)}); err != errEncryptionRequired {
+		t.Fatal("plaintext request accepted", err)
+	}
+}
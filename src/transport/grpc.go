@@ -0,0 +1,143 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/36625090/involution/logical"
+	"github.com/36625090/involution/tracing"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+	"net/http"
+	"strings"
+)
+
+const (
+	GRPCServiceName  = "involution.Involution"
+	GRPCInvokeMethod = "/" + GRPCServiceName + "/Invoke"
+)
+
+//grpcHeaders 从gRPC metadata复制到请求头的键，metadata键为请求头的小写形式
+var grpcHeaders = []logical.HeaderKey{
+	logical.HeaderTraceIDKey,
+	logical.HeaderTraceparentKey,
+	logical.HeaderTracestateKey,
+	logical.HeaderClientIDKey,
+	logical.HeaderAuthorizationKey,
+	logical.HeaderSignKeyVersionKey,
+	logical.HeaderIdempotencyKey,
+}
+
+//GRPCServer gRPC通用调用服务，请求和响应均为google.protobuf.Struct，proto定义参考involution.proto
+//请求字段: backend、endpoint、operation、data，以及与请求信封相同的timestamp、version、nonce、sign、sign_type、encrypt、encrypted_key
+//响应字段与HTTP响应信封一致
+type GRPCServer interface {
+	Invoke(context.Context, *structpb.Struct) (*structpb.Struct, error)
+}
+
+type grpcServer struct {
+	transport *Transport
+	handle    Handle
+}
+
+//RegisterGRPC 注册gRPC通用调用服务
+//请求转换为method=backend.endpoint.operation的请求信封，与HTTP请求一样验签、防重放、解密、处理并签名响应
+//data中的数字按float64传输，签名规范化时使用其最短十进制表示
+func (m *Transport) RegisterGRPC(s *grpc.Server, handle Handle) {
+	m.logger.Info("initialize grpc service", "method", GRPCInvokeMethod)
+	s.RegisterService(&grpcServiceDesc, &grpcServer{transport: m, handle: handle})
+}
+
+func (s *grpcServer) Invoke(ctx context.Context, in *structpb.Struct) (*structpb.Struct, error) {
+	body, err := grpcEnvelope(in)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, GRPCInvokeMethod, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.RequestURI = GRPCInvokeMethod
+	req.Header.Set("Content-Type", MIMEJSON)
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range grpcHeaders {
+		if values := md.Get(strings.ToLower(key.String())); len(values) > 0 {
+			req.Header.Set(key.String(), values[0])
+		}
+	}
+	trace := logical.ResolveTrace(req.Header)
+	spanCtx, span := tracing.StartServer(ctx, req.Header, trace, GRPCInvokeMethod)
+	defer span.End()
+	req = req.WithContext(spanCtx)
+	trace.Inject(req.Header)
+
+	c := NewContext(&gin.Context{Request: req})
+	c.body = body
+	s.transport.serve(c, s.handle)
+	header := metadata.Pairs(strings.ToLower(logical.HeaderTraceIDKey.String()), c.response.TraceID,
+		logical.HeaderTraceparentKey.String(), trace.Traceparent())
+	if trace.State != "" {
+		header.Set(logical.HeaderTracestateKey.String(), trace.State)
+	}
+	if c.signKeyVersion != "" {
+		header.Set(strings.ToLower(logical.HeaderSignKeyVersionKey.String()), c.signKeyVersion)
+	}
+	if c.signVersion != "" {
+		header.Set(strings.ToLower(logical.HeaderSignVersionKey.String()), c.signVersion)
+	}
+	_ = grpc.SetHeader(ctx, header)
+
+	value, err := jsonValue(c.response)
+	if err != nil {
+		return nil, err
+	}
+	pv, err := toProtoValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return pv.GetStructValue(), nil
+}
+
+//grpcEnvelope 将gRPC请求转换为JSON请求信封
+func grpcEnvelope(in *structpb.Struct) ([]byte, error) {
+	fields, _ := fromProtoValue(&structpb.Value{Kind: &structpb.Value_StructValue{StructValue: in}}).(map[string]interface{})
+	if fields == nil {
+		return nil, errors.New("grpc request is empty")
+	}
+	var method []string
+	for _, key := range []string{"backend", "endpoint", "operation"} {
+		value, _ := fields[key].(string)
+		method = append(method, value)
+		delete(fields, key)
+	}
+	fields["method"] = strings.Join(method, ".")
+	return json.Marshal(fields)
+}
+
+func grpcInvokeHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(structpb.Struct)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(GRPCServer).Invoke(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: GRPCInvokeMethod}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(GRPCServer).Invoke(ctx, req.(*structpb.Struct))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var grpcServiceDesc = grpc.ServiceDesc{
+	ServiceName: GRPCServiceName,
+	HandlerType: (*GRPCServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Invoke", Handler: grpcInvokeHandler},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "involution.proto",
+}
@@ -0,0 +1,62 @@
+package transport
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+	"net"
+	"testing"
+)
+
+func TestTransport_GRPC(t *testing.T) {
+	tr := newTestTransport(t, &Settings{DefaultPolicy: SignPolicyAllow})
+	listener := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	tr.RegisterGRPC(s, func(c *Context) error {
+		args, err := c.DecodeArgs()
+		if err != nil {
+			return err
+		}
+		c.WithContent(c.Request().Method + ":" + c.GetClientID() + ":" + args.GetTraceID())
+		return nil
+	})
+	go s.Serve(listener)
+	defer s.Stop()
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, s string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	str := func(s string) *structpb.Value {
+		return &structpb.Value{Kind: &structpb.Value_StringValue{StringValue: s}}
+	}
+	in := &structpb.Struct{Fields: map[string]*structpb.Value{
+		"backend":   str("account"),
+		"endpoint":  str("user"),
+		"operation": str("home"),
+		"data":      {Kind: &structpb.Value_StructValue{StructValue: &structpb.Struct{}}},
+		"timestamp": {Kind: &structpb.Value_NumberValue{NumberValue: 1}},
+		"version":   str("1.0"),
+		"sign":      str("-"),
+		"sign_type": str(SignTypeMD5),
+	}}
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		"x-client-id", "client-1", "x-trace-id", "trace-1")
+	out := new(structpb.Struct)
+	var header metadata.MD
+	if err := conn.Invoke(ctx, GRPCInvokeMethod, in, out, grpc.Header(&header)); err != nil {
+		t.Fatal(err)
+	}
+	if content := out.Fields["content"].GetStringValue(); content != "account.user.home:client-1:trace-1" {
+		t.Fatal("unexpected content", content, out)
+	}
+	if trace := header.Get("x-trace-id"); len(trace) != 1 || trace[0] != "trace-1" {
+		t.Fatal("unexpected trace header", header)
+	}
+}
@@ -0,0 +1,305 @@
+package transport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/36625090/involution/logical/codes"
+	"github.com/go-various/redisplus"
+	"gopkg.in/redis.v5"
+	"sync"
+	"time"
+)
+
+const (
+	IdempotencyStoreMemory = "memory"
+	IdempotencyStoreRedis  = "redis"
+
+	defaultIdempotencyTTL     = 24 * 60 * 60
+	defaultIdempotencyLockTTL = 5 * 60
+	defaultIdempotencyWait    = 10
+
+	idempotencyPollInterval = 100 * time.Millisecond
+)
+
+var ErrIdempotencyInProgress = errors.New("request with the same idempotency key is in progress")
+var ErrIdempotencyMismatch = errors.New("idempotency key was used with a different request")
+
+//transientCodes 系统、鉴权及验签等非确定性的失败，响应不保存，重复请求重新执行
+var transientCodes = map[int]bool{
+	codes.CodeFatal.Int():                 true,
+	codes.CodeServerInternalError.Int():   true,
+	codes.CodeOperationHandlerIssue.Int(): true,
+	codes.CodeBackendIssue.Int():          true,
+	codes.CodeInvalidSignature.Int():      true,
+	codes.CodeHandleRequest.Int():         true,
+	codes.CodeReplayedRequest.Int():       true,
+	codes.CodeBatchAborted.Int():          true,
+	codes.CodeInvalidEncryption.Int():     true,
+	codes.CodeIdempotencyConflict.Int():   true,
+	codes.CodeReadRequestBody.Int():       true,
+	codes.CodeServiceException.Int():     true,
+	codes.CodeUnauthorized.Int():          true,
+}
+
+//IdempotencySettings 幂等请求的配置
+type IdempotencySettings struct {
+	//Store 存储方式 redis(默认) memory(仅用于单节点部署)
+	Store string `json:"store" hcl:"store"`
+	//TTL 响应保存时长(秒)，默认24小时
+	TTL int64 `json:"ttl" hcl:"ttl"`
+	//LockTTL 执行中标记的有效期(秒)，进程异常退出时到期后允许重新执行，默认300
+	LockTTL int64 `json:"lock_ttl" hcl:"lock_ttl"`
+	//Wait 重复请求等待首个请求完成的最长时间(秒)，默认10
+	Wait int64 `json:"wait" hcl:"wait"`
+}
+
+func (s *IdempotencySettings) duration(value, fallback int64) time.Duration {
+	if value <= 0 {
+		value = fallback
+	}
+	return time.Duration(value) * time.Second
+}
+
+//IdempotencyStore 幂等请求结果的存储接口
+type IdempotencyStore interface {
+	//Acquire key不存在时写入value并返回true
+	Acquire(key string, value []byte, ttl time.Duration) (bool, error)
+	//Load 读取key，不存在时返回nil
+	Load(key string) ([]byte, error)
+	Save(key string, value []byte, ttl time.Duration) error
+	Delete(key string) error
+}
+
+//idempotencyEntry 存储的幂等记录，Response为签名后的完整响应
+type idempotencyEntry struct {
+	Pending        bool            `json:"pending,omitempty"`
+	Fingerprint    string          `json:"fingerprint"`
+	Response       json.RawMessage `json:"response,omitempty"`
+	SignKeyVersion string          `json:"sign_key_version,omitempty"`
+	SignVersion    string          `json:"sign_version,omitempty"`
+}
+
+//storedResponse 存储的响应，content和pagination保留原始JSON，重放时与首次响应一致
+type storedResponse struct {
+	Response
+	Content    json.RawMessage `json:"content"`
+	Pagination json.RawMessage `json:"pagination"`
+}
+
+type idempotency struct {
+	settings *IdempotencySettings
+	store    IdempotencyStore
+}
+
+//SetIdempotency 开启幂等请求，store为nil时使用内存存储，仅适用于单节点部署
+func (m *Transport) SetIdempotency(settings *IdempotencySettings, store IdempotencyStore) {
+	if nil == settings {
+		settings = &IdempotencySettings{}
+	}
+	if nil == store {
+		store = NewMemoryIdempotencyStore()
+	}
+	m.idempotency = &idempotency{settings: settings, store: store}
+}
+
+//Idempotent 按key执行幂等请求，需在处理函数中业务逻辑之前调用
+//首个请求返回false，成功或确定性的业务失败的签名响应在serve结束时保存，参考saveIdempotent；
+//重复请求等待首个请求完成并复用其响应，返回true，此时不应再执行业务逻辑
+//相同key但请求数据不同时返回ErrIdempotencyMismatch，等待超时返回ErrIdempotencyInProgress
+func (m *Transport) Idempotent(ctx *Context, key string) (bool, error) {
+	if m.idempotency == nil || key == "" {
+		return false, nil
+	}
+	settings, store := m.idempotency.settings, m.idempotency.store
+
+	hash := sha256.Sum256(append([]byte(ctx.request.Method+"\n"), ctx.request.Data...))
+	fingerprint := hex.EncodeToString(hash[:])
+	pending, _ := json.Marshal(&idempotencyEntry{Pending: true, Fingerprint: fingerprint})
+
+	deadline := time.Now().Add(settings.duration(settings.Wait, defaultIdempotencyWait))
+	for {
+		acquired, err := store.Acquire(key, pending, settings.duration(settings.LockTTL, defaultIdempotencyLockTTL))
+		if err != nil {
+			return false, err
+		}
+		if acquired {
+			ctx.onSigned = append(ctx.onSigned, func(c *Context) {
+				m.saveIdempotent(c, key, fingerprint)
+			})
+			return false, nil
+		}
+
+		bs, err := store.Load(key)
+		if err != nil {
+			return false, err
+		}
+		//首个请求在Acquire和Load之间结束且未保存结果时重新获取
+		if bs != nil {
+			var entry idempotencyEntry
+			if err := json.Unmarshal(bs, &entry); err != nil {
+				return false, err
+			}
+			if entry.Fingerprint != fingerprint {
+				return false, ErrIdempotencyMismatch
+			}
+			if !entry.Pending {
+				return true, ctx.reuse(&entry)
+			}
+		}
+		if time.Now().After(deadline) {
+			return false, ErrIdempotencyInProgress
+		}
+		time.Sleep(idempotencyPollInterval)
+	}
+}
+
+//saveIdempotent 保存签名后的响应
+//下载响应无法重放，系统、鉴权及验签等失败可能在重试时成功，均删除执行中标记
+func (m *Transport) saveIdempotent(ctx *Context, key string, fingerprint string) {
+	settings, store := m.idempotency.settings, m.idempotency.store
+	if ctx.download != nil || transientCodes[ctx.response.Code] {
+		if err := store.Delete(key); err != nil {
+			m.logger.Error("delete idempotency key", "key", key, "err", err)
+		}
+		return
+	}
+	resp, err := json.Marshal(ctx.response)
+	if err == nil {
+		var bs []byte
+		bs, err = json.Marshal(&idempotencyEntry{Fingerprint: fingerprint, Response: resp,
+			SignKeyVersion: ctx.signKeyVersion, SignVersion: ctx.signVersion})
+		if err == nil {
+			err = store.Save(key, bs, settings.duration(settings.TTL, defaultIdempotencyTTL))
+		}
+	}
+	if err != nil {
+		m.logger.Error("save idempotency response", "key", key, "err", err)
+		_ = store.Delete(key)
+	}
+}
+
+//reuse 使用保存的签名响应，跳过加密和签名
+func (c *Context) reuse(entry *idempotencyEntry) error {
+	var stored storedResponse
+	if err := json.Unmarshal(entry.Response, &stored); err != nil {
+		return err
+	}
+	resp := stored.Response
+	resp.Content, resp.Pagination = storedValue(c, stored.Content), storedValue(c, stored.Pagination)
+	c.response = &resp
+	c.signKeyVersion = entry.SignKeyVersion
+	c.signVersion = entry.SignVersion
+	c.reused = true
+	return nil
+}
+
+//storedValue JSON响应保留原始内容，其他编码解码后重新编码
+func storedValue(c *Context, raw json.RawMessage) interface{} {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	if c.ctx != nil && responseEncoding(c.ctx.GetHeader("Accept"), requestEncoding(c.ctx.ContentType())) == (jsonEncoding{}) {
+		return raw
+	}
+	var value interface{}
+	_ = json.Unmarshal(raw, &value)
+	return value
+}
+
+type memoryIdempotencyStore struct {
+	sync.Mutex
+	entries map[string]*memoryIdempotencyEntry
+	cleanAt time.Time
+}
+
+type memoryIdempotencyEntry struct {
+	value    []byte
+	expireAt time.Time
+}
+
+//NewMemoryIdempotencyStore 单节点使用的内存存储
+func NewMemoryIdempotencyStore() IdempotencyStore {
+	return &memoryIdempotencyStore{entries: map[string]*memoryIdempotencyEntry{}, cleanAt: time.Now()}
+}
+
+func (m *memoryIdempotencyStore) Acquire(key string, value []byte, ttl time.Duration) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	now := time.Now()
+	if now.Sub(m.cleanAt) > time.Minute {
+		for k, entry := range m.entries {
+			if now.After(entry.expireAt) {
+				delete(m.entries, k)
+			}
+		}
+		m.cleanAt = now
+	}
+	if entry, ok := m.entries[key]; ok && now.Before(entry.expireAt) {
+		return false, nil
+	}
+	m.entries[key] = &memoryIdempotencyEntry{value: value, expireAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (m *memoryIdempotencyStore) Load(key string) ([]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+	if entry, ok := m.entries[key]; ok && time.Now().Before(entry.expireAt) {
+		return entry.value, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryIdempotencyStore) Save(key string, value []byte, ttl time.Duration) error {
+	m.Lock()
+	defer m.Unlock()
+	m.entries[key] = &memoryIdempotencyEntry{value: value, expireAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *memoryIdempotencyStore) Delete(key string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+type redisIdempotencyStore struct {
+	redis redisplus.RedisCli
+}
+
+//NewRedisIdempotencyStore 集群使用的redis存储
+func NewRedisIdempotencyStore(config *redisplus.Config) (IdempotencyStore, error) {
+	if nil == config {
+		return nil, errors.New("redis config is nil")
+	}
+	cli, err := redisplus.NewRedisCli(config, "idempotency")
+	if err != nil {
+		return nil, err
+	}
+	return &redisIdempotencyStore{redis: cli}, nil
+}
+
+func (r *redisIdempotencyStore) Acquire(key string, value []byte, ttl time.Duration) (bool, error) {
+	return r.redis.SetNX(key, value, ttl.String())
+}
+
+//Load redisplus的Get不区分key不存在，使用原生命令
+func (r *redisIdempotencyStore) Load(key string) ([]byte, error) {
+	bs, err := r.redis.NativeCmd().Get(r.redis.KeyPrefix() + redisplus.RedisKeySep + key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	return bs, err
+}
+
+func (r *redisIdempotencyStore) Save(key string, value []byte, ttl time.Duration) error {
+	return r.redis.Set(key, value, ttl.String())
+}
+
+func (r *redisIdempotencyStore) Delete(key string) error {
+	_, err := r.redis.Del(key)
+	return err
+}
@@ -0,0 +1,130 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	ResponseSignKeyGlobal = "global"
+	ResponseSignKeyClient = "client"
+)
+
+var errSignKeyNotActive = errors.New("signature key version not active")
+
+//KeyID 签名密钥标识
+//ID 为X-Client-ID或GlobalSignKey，Version 为X-Sign-Key-Version，为空时使用当前生效的密钥
+type KeyID struct {
+	ID      string
+	Version string
+}
+
+//SignKey 带版本和有效期的签名密钥，用于不停机轮换
+//rotating_keys = [
+//  {
+//    client_id = "user1"
+//    version = "v2"
+//    secret = "..."
+//    not_before = "2026-01-01T00:00:00Z"
+//    expires_at = "2026-06-01T00:00:00Z"
+//  }
+//]
+type SignKey struct {
+	ClientID  string `json:"client_id" hcl:"client_id"`
+	Version   string `json:"version" hcl:"version"`
+	Secret    string `json:"-" hcl:"secret"`
+	NotBefore string `json:"not_before" hcl:"not_before"`
+	ExpiresAt string `json:"expires_at" hcl:"expires_at"`
+}
+
+//Active 密钥在指定时间是否生效
+func (k *SignKey) Active(now time.Time) bool {
+	if k.NotBefore != "" {
+		if t, err := time.Parse(time.RFC3339, k.NotBefore); err != nil || now.Before(t) {
+			return false
+		}
+	}
+	if k.ExpiresAt != "" {
+		if t, err := time.Parse(time.RFC3339, k.ExpiresAt); err != nil || !now.Before(t) {
+			return false
+		}
+	}
+	return true
+}
+
+func (k *SignKey) validate() error {
+	if k.ClientID == "" || k.Version == "" || k.Secret == "" {
+		return errors.New("rotating key requires client_id, version and secret")
+	}
+	for _, v := range []string{k.NotBefore, k.ExpiresAt} {
+		if v == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			return fmt.Errorf("rotating key %s@%s: %v", k.ClientID, k.Version, err)
+		}
+	}
+	return nil
+}
+
+//Secret 查找对称签名密钥
+//指定版本时必须在有效期内，未指定版本时使用最晚生效的轮换密钥，没有轮换密钥时使用sign_keys
+func (s *Settings) Secret(kid KeyID, now time.Time) (string, error) {
+	if kid.Version == "" {
+		if key := s.currentKey(kid.ID, now); key != nil {
+			return key.Secret, nil
+		}
+		if secret := s.SignKeys[kid.ID]; secret != "" {
+			return secret, nil
+		}
+		return "", errors.New(errInvalidSignKey.Error() + ": " + kid.ID)
+	}
+
+	for _, key := range s.RotatingKeys {
+		if key.ClientID != kid.ID || key.Version != kid.Version {
+			continue
+		}
+		if !key.Active(now) {
+			return "", fmt.Errorf("%s: %s@%s", errSignKeyNotActive, kid.ID, kid.Version)
+		}
+		return key.Secret, nil
+	}
+	return "", fmt.Errorf("%s: %s@%s", errInvalidSignKey, kid.ID, kid.Version)
+}
+
+//CurrentVersion 返回当前生效的密钥版本，没有轮换密钥时返回空
+func (s *Settings) CurrentVersion(id string, now time.Time) string {
+	if key := s.currentKey(id, now); key != nil {
+		return key.Version
+	}
+	return ""
+}
+
+func (s *Settings) currentKey(id string, now time.Time) *SignKey {
+	var current *SignKey
+	var currentAt time.Time
+	for _, key := range s.RotatingKeys {
+		if key.ClientID != id || !key.Active(now) {
+			continue
+		}
+		notBefore, _ := time.Parse(time.RFC3339, key.NotBefore)
+		if current == nil || notBefore.After(currentAt) {
+			current, currentAt = key, notBefore
+		}
+	}
+	return current
+}
+
+func (s *Settings) validateKeys() error {
+	for _, key := range s.RotatingKeys {
+		if err := key.validate(); err != nil {
+			return err
+		}
+	}
+	switch s.ResponseSignKey {
+	case "", ResponseSignKeyGlobal, ResponseSignKeyClient:
+		return nil
+	}
+	return fmt.Errorf("invalid response_sign_key: %s", s.ResponseSignKey)
+}
@@ -0,0 +1,20 @@
+package transport
+
+//Middleware 包装Handle的中间件，在验签、防重放和解密之后，响应加密和签名之前执行
+//不调用next即短路，此时ctx中设置的响应照常签名返回；返回error时与Handle返回error的处理一致
+type Middleware func(next Handle) Handle
+
+//AddMiddleware 按顺序添加中间件，先添加的在外层，需在开始处理请求之前调用
+//中间件作用于HTTP、批量请求、gRPC、WebSocket的请求信封，以及经ServeRequest、HandleRequest处理的JSON-RPC调用和RESTful路由请求
+//RESTful路由的Request().Data为合并后的操作输入，验签使用的data参考server.initRESTRoutes
+func (m *Transport) AddMiddleware(middlewares ...Middleware) {
+	m.middlewares = append(m.middlewares, middlewares...)
+}
+
+//chain 使用中间件包装handle
+func (m *Transport) chain(handle Handle) Handle {
+	for i := len(m.middlewares) - 1; i >= 0; i-- {
+		handle = m.middlewares[i](handle)
+	}
+	return handle
+}
@@ -0,0 +1,105 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-various/redisplus"
+	"sync"
+	"time"
+)
+
+const (
+	NonceStoreMemory = "memory"
+	NonceStoreRedis  = "redis"
+)
+
+var errNonceRequired = errors.New("nonce is required")
+var errReplayedRequest = errors.New("replayed request")
+
+//NonceStore 已使用nonce的存储接口
+type NonceStore interface {
+	//Seen 记录nonce并在ttl后过期，nonce已存在时返回true
+	Seen(key string, ttl time.Duration) (bool, error)
+}
+
+//replayGuard 基于时间戳窗口和nonce的防重放校验
+type replayGuard struct {
+	skew  time.Duration
+	store NonceStore
+}
+
+func (g *replayGuard) check(clientId string, req *Request) error {
+	now := time.Now().UnixMilli()
+	if diff := now - req.Timestamp; diff > g.skew.Milliseconds() || -diff > g.skew.Milliseconds() {
+		return fmt.Errorf("request timestamp %d out of %v window", req.Timestamp, g.skew)
+	}
+
+	if "" == req.Nonce {
+		return errNonceRequired
+	}
+
+	//时间窗口外的请求已被拒绝，nonce只需保留窗口的两倍时长
+	seen, err := g.store.Seen(clientId+":"+req.Nonce, g.skew*2)
+	if err != nil {
+		return fmt.Errorf("nonce store: %v", err)
+	}
+	if seen {
+		return errReplayedRequest
+	}
+	return nil
+}
+
+type memoryNonceStore struct {
+	sync.Mutex
+	nonces  map[string]time.Time
+	cleanAt time.Time
+}
+
+//NewMemoryNonceStore 单节点使用的内存nonce存储
+func NewMemoryNonceStore() NonceStore {
+	return &memoryNonceStore{nonces: map[string]time.Time{}, cleanAt: time.Now()}
+}
+
+func (m *memoryNonceStore) Seen(key string, ttl time.Duration) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	now := time.Now()
+	if now.Sub(m.cleanAt) > ttl {
+		for k, expireAt := range m.nonces {
+			if now.After(expireAt) {
+				delete(m.nonces, k)
+			}
+		}
+		m.cleanAt = now
+	}
+
+	if expireAt, ok := m.nonces[key]; ok && now.Before(expireAt) {
+		return true, nil
+	}
+	m.nonces[key] = now.Add(ttl)
+	return false, nil
+}
+
+type redisNonceStore struct {
+	redis redisplus.RedisCli
+}
+
+//NewRedisNonceStore 集群使用的redis nonce存储
+func NewRedisNonceStore(config *redisplus.Config) (NonceStore, error) {
+	if nil == config {
+		return nil, errors.New("redis config is nil")
+	}
+	cli, err := redisplus.NewRedisCli(config, "nonce")
+	if err != nil {
+		return nil, err
+	}
+	return &redisNonceStore{redis: cli}, nil
+}
+
+func (r *redisNonceStore) Seen(key string, ttl time.Duration) (bool, error) {
+	ok, err := r.redis.SetNX(key, []byte{1}, ttl.String())
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
@@ -7,12 +7,19 @@ import (
 
 //Request 客户端请求数据结构 Data会被解码传到后端真正的服务逻辑
 type Request struct {
-	Method    string `json:"method" binding:"required"`
-	Data      string `json:"data" binding:"required"`
-	Timestamp int64  `json:"timestamp" binding:"required"`
-	Version   string `json:"version" binding:"required"`
-	Sign      string `json:"sign" binding:"required"`
-	SignType  string `json:"sign_type" choices:"md5" binding:"required"`
+	Method    string `json:"method" xml:"method" binding:"required"`
+	Data      Data   `json:"data" xml:"data" binding:"required"`
+	Timestamp int64  `json:"timestamp" xml:"timestamp" binding:"required"`
+	Nonce     string `json:"nonce" xml:"nonce"`
+	Version   string `json:"version" xml:"version" binding:"required"`
+	Sign      string `json:"sign" xml:"sign" binding:"required"`
+	SignType  string `json:"sign_type" xml:"sign_type" binding:"required"`
+	//Encrypt 加密模式，为空时data为明文，参考EncryptRSA EncryptPSK
+	Encrypt string `json:"encrypt,omitempty" xml:"encrypt,omitempty"`
+	//EncryptedKey 包装后的数据密钥
+	EncryptedKey string `json:"encrypted_key,omitempty" xml:"encrypted_key,omitempty"`
+	//Files multipart上传文件的SHA256摘要 字段名 => hex，参与签名
+	Files map[string]string `json:"files,omitempty" xml:"-"`
 }
 
 func (r *Request) Backend() string {
@@ -28,18 +35,26 @@ func (r *Request) Operation() string {
 }
 
 func (r *Request) Keys() []string {
-	keys := []string{"method", "data", "timestamp", "version", "sign", "sign_type"}
+	keys := []string{"method", "data", "timestamp", "nonce", "version", "sign", "sign_type", "encrypt", "encrypted_key", "files"}
 	sort.Strings(keys)
 	return keys
 }
 
 func (r *Request) Map() map[string]interface{} {
 	params := make(map[string]interface{})
-	params["data"] = r.Data
+	if data, err := r.Data.Canonical(); err == nil {
+		params["data"] = data
+	} else {
+		params["data"] = string(r.Data)
+	}
 	params["method"] = r.Method
 	params["sign_type"] = r.SignType
 	params["timestamp"] = r.Timestamp
+	params["nonce"] = r.Nonce
 	params["version"] = r.Version
 	params["sign"] = r.Sign
+	params["encrypt"] = r.Encrypt
+	params["encrypted_key"] = r.EncryptedKey
+	params["files"] = canonicalFiles(r.Files)
 	return params
 }
@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"bytes"
 	"encoding/json"
 	"github.com/36625090/involution/utils"
 	"sort"
@@ -15,10 +16,13 @@ type Response struct {
 	TraceID    string      `json:"trace_id" xml:"trace_id"`
 	Timestamp  int64       `json:"timestamp" xml:"timestamp"`
 	Sign       string      `json:"sign" xml:"sign"`
+	//Encrypt 加密模式，content为加密后的base64字符串
+	Encrypt      string `json:"encrypt,omitempty" xml:"encrypt,omitempty"`
+	EncryptedKey string `json:"encrypted_key,omitempty" xml:"encrypted_key,omitempty"`
 }
 
 func (r *Response) Keys() []string {
-	keys := []string{"code", "message", "content", "trace_id", "timestamp", "sign","pagination"}
+	keys := []string{"code", "message", "content", "trace_id", "timestamp", "sign","pagination", "encrypt", "encrypted_key"}
 	sort.Strings(keys)
 	return keys
 }
@@ -26,14 +30,10 @@ func (r *Response) Keys() []string {
 type AX struct {
 
 }
+//Map v1签名串使用的字段，content和pagination为JSON编码
 func (r *Response) Map() map[string]interface{} {
 
-	params := make(map[string]interface{})
-	params["code"] = r.Code
-	params["message"] = r.Message
-	params["trace_id"] = r.TraceID
-	params["timestamp"] = r.Timestamp
-	params["sign"] = r.Sign
+	params := r.fields()
 	if ! utils.IsNil(r.Content){
 		bs, _ := json.Marshal(r.Content)
 		params["content"] = string(bs)
@@ -44,3 +44,91 @@ func (r *Response) Map() map[string]interface{} {
 	}
 	return params
 }
+
+//CanonicalMap v2签名串使用的字段，content和pagination使用CanonicalContent，与响应的传输编码无关
+func (r *Response) CanonicalMap() map[string]interface{} {
+	params := r.fields()
+	if content, _ := CanonicalContent(r.Content); content != "" {
+		params["content"] = content
+	}
+	if pagination, _ := CanonicalContent(r.Pagination); pagination != "" {
+		params["pagination"] = pagination
+	}
+	return params
+}
+
+func (r *Response) fields() map[string]interface{} {
+	params := make(map[string]interface{})
+	params["code"] = r.Code
+	params["message"] = r.Message
+	params["trace_id"] = r.TraceID
+	params["timestamp"] = r.Timestamp
+	params["sign"] = r.Sign
+	params["encrypt"] = r.Encrypt
+	params["encrypted_key"] = r.EncryptedKey
+	return params
+}
+
+//CanonicalContent v2签名中响应content和pagination的规范化字符串
+//规范化规则与请求的Data.Canonical一致:
+//  空值: 空字符串
+//  字符串: 使用字符串本身
+//  其他: 按JSON结构，键按字典序排序、无空白、不转义HTML字符、数字保持JSON编码的原样的紧凑JSON
+//客户端按任意编码(JSON XML MessagePack Protobuf)解码得到的通用结构可重新生成相同的结果，
+//Protobuf的数字为double，超过2^53的整数无法还原
+func CanonicalContent(v interface{}) (string, error) {
+	if utils.IsNil(v) {
+		return "", nil
+	}
+	value, err := jsonValue(v)
+	if err != nil {
+		return "", err
+	}
+	return canonicalJSON(value)
+}
+
+//canonicalJSON 通用结构的规范化字符串，参考CanonicalContent
+func canonicalJSON(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(buf.Bytes(), "\n")), nil
+}
+
+//convertNumbers 转换jsonValue结果中的json.Number
+func convertNumbers(value interface{}, convert func(json.Number) (interface{}, error)) (interface{}, error) {
+	switch v := value.(type) {
+	case json.Number:
+		return convert(v)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			converted, err := convertNumbers(item, convert)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = converted
+		}
+		return list, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted, err := convertNumbers(item, convert)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = converted
+		}
+		return m, nil
+	}
+	return value, nil
+}
@@ -10,7 +10,50 @@ const (
 const GlobalSignKey = "global"
 
 type Settings struct {
-	SignType      string            `json:"sign_type" hcl:"sign_type" default:"md5"`
+	SignType string `json:"sign_type" hcl:"sign_type" default:"md5"`
+	//SignTypes 服务端接受的签名算法列表，迁移期间可同时开启多个，为空时仅接受SignType
+	SignTypes     []string          `json:"sign_types" hcl:"sign_types"`
 	SignKeys      map[string]string `json:"sign_keys" hcl:"sign_keys"`
 	DefaultPolicy SignPolicy        `json:"default_policy" hcl:"default_policy"`
-}
\ No newline at end of file
+	//RotatingKeys 多版本对称密钥，客户端通过X-Sign-Key-Version选择版本，优先于sign_keys
+	RotatingKeys []*SignKey `json:"rotating_keys" hcl:"rotating_keys"`
+	//ResponseSignKey 响应签名密钥 global(默认，使用GlobalSignKey) client(使用客户端自己的密钥)
+	ResponseSignKey string `json:"response_sign_key" hcl:"response_sign_key"`
+	//PublicKeys 非对称签名的客户端公钥 X-Client-ID => base64(PKIX/PKCS1 DER)或PEM
+	PublicKeys map[string]string `json:"public_keys" hcl:"public_keys"`
+	//PrivateKeys 非对称签名的服务端私钥 sign_type => base64(PKCS8 DER)或PEM，用于响应签名
+	PrivateKeys map[string]string `json:"-" hcl:"private_keys"`
+	//DefaultSignVersion 签名串版本 v1(默认) v2，参考canonicalV2
+	DefaultSignVersion string `json:"sign_version" hcl:"sign_version"`
+	//SignVersions 按客户端指定签名串版本 X-Client-ID => v1|v2，优先于sign_version
+	SignVersions map[string]string `json:"sign_versions" hcl:"sign_versions"`
+	//TimestampSkew 请求时间戳允许的时钟偏差(秒)，大于0时开启防重放校验，请求必须携带nonce
+	TimestampSkew int64 `json:"timestamp_skew" hcl:"timestamp_skew"`
+	//NonceStore nonce存储方式 memory(单节点) redis(集群)，默认memory
+	NonceStore string `json:"nonce_store" hcl:"nonce_store"`
+	//BatchSize 批量请求最多包含的请求数，默认20
+	BatchSize int `json:"batch_size" hcl:"batch_size"`
+	//BatchConcurrency 批量请求的并发数，默认4
+	BatchConcurrency int `json:"batch_concurrency" hcl:"batch_concurrency"`
+	//UploadMaxSize multipart请求上传文件的总大小上限(字节)，默认32MB，单个操作的上限参考framework.UploadField
+	UploadMaxSize int64 `json:"upload_max_size" hcl:"upload_max_size"`
+	//Idempotency 幂等请求，未配置时不开启，参考framework.EndpointOperation.Idempotent
+	Idempotency *IdempotencySettings `json:"idempotency" hcl:"idempotency"`
+	//StatusAlwaysOK 为true时所有响应返回HTTP 200，兼容旧客户端
+	StatusAlwaysOK bool `json:"status_always_ok" hcl:"status_always_ok"`
+	//StatusMapping 业务状态码到HTTP状态码的映射，优先于默认映射，参考HTTPStatus
+	StatusMapping []*StatusRule `json:"status_mapping" hcl:"status_mapping"`
+	//Encryption 端到端数据加密，未配置时不开启
+	Encryption *EncryptionSettings `json:"encryption" hcl:"encryption"`
+}
+
+//AcceptedSignTypes 返回服务端接受的签名算法
+func (s *Settings) AcceptedSignTypes() []string {
+	if len(s.SignTypes) > 0 {
+		return s.SignTypes
+	}
+	if s.SignType != "" {
+		return []string{s.SignType}
+	}
+	return []string{SignTypeMD5}
+}
@@ -7,22 +7,72 @@ import (
 	"errors"
 	"fmt"
 	"github.com/36625090/involution/utils"
+	"time"
 )
 
 var errInvalidSign = errors.New("invalid signature")
 var errInvalidHeaderSignKey = errors.New("headers[X-Client-ID] cannot be empty")
 var errInvalidSignKey = errors.New("invalid signature key id")
 var errInvalidDefaultSignKey = errors.New("invalid signature global default key")
+var errUnsupportedSignType = errors.New("unsupported sign type")
+
+const (
+	SignTypeMD5        = "md5"
+	SignTypeHmacSHA256 = "hmac-sha256"
+	SignTypeHmacSHA512 = "hmac-sha512"
+)
 
 type Signer interface {
-	Sign(keyId string, resp Codec) (string, error)
-	Verify(keyId, sign string, req Codec) error
+	Sign(kid KeyID, resp Codec) (string, error)
+	Verify(kid KeyID, sign string, req Codec) error
+}
+
+//SignerFactory 签名器构造函数，按SignType注册
+type SignerFactory func(settings *Settings) (Signer, error)
+
+var signerFactories = map[string]SignerFactory{
+	SignTypeMD5: func(settings *Settings) (Signer, error) {
+		return NewMD5Signer(settings), nil
+	},
+	SignTypeHmacSHA256: func(settings *Settings) (Signer, error) {
+		return NewHmacSHA256Signer(settings), nil
+	},
+	SignTypeHmacSHA512: func(settings *Settings) (Signer, error) {
+		return NewHmacSHA512Signer(settings), nil
+	},
+	SignTypeRSASHA256: NewRSASigner,
+	SignTypeEd25519:   NewEd25519Signer,
+}
+
+//RegisterSigner 注册自定义签名算法，需在NewTransport之前调用
+func RegisterSigner(signType string, factory SignerFactory) {
+	signerFactories[signType] = factory
+}
+
+//NewSigners 根据配置创建服务端接受的全部签名器
+func NewSigners(settings *Settings) (map[string]Signer, error) {
+	signers := make(map[string]Signer)
+	for _, signType := range settings.AcceptedSignTypes() {
+		factory, ok := signerFactories[signType]
+		if !ok {
+			return nil, fmt.Errorf("%s: %s", errUnsupportedSignType, signType)
+		}
+		s, err := factory(settings)
+		if err != nil {
+			return nil, fmt.Errorf("create signer %s: %v", signType, err)
+		}
+		signers[signType] = s
+	}
+	return signers, nil
 }
 
 type signer struct {
 }
 
 func (m *signer) build(req Codec) *bytes.Buffer {
+	if c, ok := req.(canonicalCodec); ok {
+		return bytes.NewBuffer(c.Canonical())
+	}
 	params := req.Map()
 	var buf bytes.Buffer
 	for _, v := range req.Keys() {
@@ -44,15 +94,15 @@ func NewMD5Signer(settings *Settings) Signer {
 }
 
 //Sign 签名方法
-//keyId keys id
-func (m *md5Signer) Sign(keyId string, resp Codec) (string, error) {
+//kid 密钥标识
+func (m *md5Signer) Sign(kid KeyID, resp Codec) (string, error) {
 	if m.settings.DefaultPolicy == SignPolicyAllow {
 		return "", nil
 	}
 
-	key := m.settings.SignKeys[keyId]
-	if "" == key{
-		return "", errInvalidDefaultSignKey
+	key, err := m.settings.Secret(kid, time.Now())
+	if err != nil {
+		return "", err
 	}
 
 	buf := m.build(resp)
@@ -61,18 +111,18 @@ func (m *md5Signer) Sign(keyId string, resp Codec) (string, error) {
 }
 
 //Verify 签名校验方法
-func (m *md5Signer) Verify(keyId, sign  string, req Codec) error {
+func (m *md5Signer) Verify(kid KeyID, sign  string, req Codec) error {
 	if m.settings.DefaultPolicy == SignPolicyAllow {
 		return nil
 	}
 
-	if "" == keyId{
+	if "" == kid.ID{
 		return errInvalidHeaderSignKey
 	}
 
-	key := m.settings.SignKeys[keyId]
-	if "" == key{
-		return errors.New(errInvalidSignKey.Error() + ": "+keyId)
+	key, err := m.settings.Secret(kid, time.Now())
+	if err != nil {
+		return err
 	}
 
 	buf := m.build(req)
@@ -0,0 +1,177 @@
+package transport
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	rsautil "github.com/36625090/involution/utils/rsa"
+	"strings"
+)
+
+const (
+	SignTypeRSASHA256 = "rsa-sha256"
+	SignTypeEd25519   = "ed25519"
+)
+
+var errInvalidPublicKey = errors.New("invalid client public key")
+
+//asymmetricSigner 非对称签名，客户端使用私钥签名请求，服务端使用私钥签名响应
+//签名结果为base64编码
+type asymmetricSigner struct {
+	signer
+	settings   *Settings
+	publicKeys map[string]crypto.PublicKey
+	privateKey crypto.PrivateKey
+	sign       func(pri crypto.PrivateKey, data []byte) ([]byte, error)
+	verify     func(pub crypto.PublicKey, data, sig []byte) error
+}
+
+func NewRSASigner(settings *Settings) (Signer, error) {
+	m := &asymmetricSigner{
+		settings: settings,
+		sign: func(pri crypto.PrivateKey, data []byte) ([]byte, error) {
+			return rsautil.SignPKCS1v15(pri.(*rsa.PrivateKey), data)
+		},
+		verify: func(pub crypto.PublicKey, data, sig []byte) error {
+			return rsautil.VerifyPKCS1v15(pub.(*rsa.PublicKey), data, sig)
+		},
+	}
+	err := m.loadKeys(SignTypeRSASHA256, func(key interface{}) bool {
+		switch key.(type) {
+		case *rsa.PublicKey, *rsa.PrivateKey:
+			return true
+		}
+		return false
+	})
+	return m, err
+}
+
+func NewEd25519Signer(settings *Settings) (Signer, error) {
+	m := &asymmetricSigner{
+		settings: settings,
+		sign: func(pri crypto.PrivateKey, data []byte) ([]byte, error) {
+			return ed25519.Sign(pri.(ed25519.PrivateKey), data), nil
+		},
+		verify: func(pub crypto.PublicKey, data, sig []byte) error {
+			if !ed25519.Verify(pub.(ed25519.PublicKey), data, sig) {
+				return errInvalidSign
+			}
+			return nil
+		},
+	}
+	err := m.loadKeys(SignTypeEd25519, func(key interface{}) bool {
+		switch key.(type) {
+		case ed25519.PublicKey, ed25519.PrivateKey:
+			return true
+		}
+		return false
+	})
+	return m, err
+}
+
+//loadKeys 加载与算法匹配的客户端公钥和服务端私钥，其他算法的公钥忽略
+func (m *asymmetricSigner) loadKeys(signType string, match func(key interface{}) bool) error {
+	m.publicKeys = make(map[string]crypto.PublicKey)
+	for clientId, encoded := range m.settings.PublicKeys {
+		pub, err := ParsePublicKey(encoded)
+		if err != nil {
+			return fmt.Errorf("parse public key of %s: %v", clientId, err)
+		}
+		if match(pub) {
+			m.publicKeys[clientId] = pub
+		}
+	}
+
+	encoded, ok := m.settings.PrivateKeys[signType]
+	if !ok {
+		return nil
+	}
+	pri, err := ParsePrivateKey(encoded)
+	if err != nil {
+		return fmt.Errorf("parse %s private key: %v", signType, err)
+	}
+	if !match(pri) {
+		return fmt.Errorf("private key type mismatch with sign type %s", signType)
+	}
+	m.privateKey = pri
+	return nil
+}
+
+//Sign 签名方法，响应统一使用服务端私钥签名
+func (m *asymmetricSigner) Sign(kid KeyID, resp Codec) (string, error) {
+	if m.settings.DefaultPolicy == SignPolicyAllow {
+		return "", nil
+	}
+
+	if nil == m.privateKey {
+		return "", errInvalidDefaultSignKey
+	}
+
+	sig, err := m.sign(m.privateKey, m.build(resp).Bytes())
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
+
+//Verify 签名校验方法，使用客户端注册的公钥
+func (m *asymmetricSigner) Verify(kid KeyID, sign string, req Codec) error {
+	if m.settings.DefaultPolicy == SignPolicyAllow {
+		return nil
+	}
+
+	if "" == kid.ID {
+		return errInvalidHeaderSignKey
+	}
+
+	pub, ok := m.publicKeys[kid.ID]
+	if !ok {
+		return errors.New(errInvalidPublicKey.Error() + ": " + kid.ID)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(sign)
+	if err != nil {
+		return errInvalidSign
+	}
+	if err := m.verify(pub, m.build(req).Bytes(), sig); err != nil {
+		return errInvalidSign
+	}
+	return nil
+}
+
+//ParsePublicKey 解析公钥，支持PEM或base64编码的PKIX、PKCS1格式
+func ParsePublicKey(encoded string) (crypto.PublicKey, error) {
+	der, err := decodeKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if pub, err := x509.ParsePKIXPublicKey(der); err == nil {
+		return pub, nil
+	}
+	return x509.ParsePKCS1PublicKey(der)
+}
+
+//ParsePrivateKey 解析私钥，支持PEM或base64编码的PKCS8、PKCS1格式
+func ParsePrivateKey(encoded string) (crypto.PrivateKey, error) {
+	der, err := decodeKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if pri, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return pri, nil
+	}
+	return x509.ParsePKCS1PrivateKey(der)
+}
+
+func decodeKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if block, _ := pem.Decode([]byte(encoded)); block != nil {
+		return block.Bytes, nil
+	}
+	return base64.StdEncoding.DecodeString(encoded)
+}
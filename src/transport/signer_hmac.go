@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"time"
+)
+
+type hmacSigner struct {
+	signer
+	settings *Settings
+	hash     func() hash.Hash
+}
+
+func NewHmacSHA256Signer(settings *Settings) Signer {
+	return &hmacSigner{settings: settings, hash: sha256.New}
+}
+
+func NewHmacSHA512Signer(settings *Settings) Signer {
+	return &hmacSigner{settings: settings, hash: sha512.New}
+}
+
+//Sign 签名方法
+//kid 密钥标识
+func (m *hmacSigner) Sign(kid KeyID, resp Codec) (string, error) {
+	if m.settings.DefaultPolicy == SignPolicyAllow {
+		return "", nil
+	}
+
+	key, err := m.settings.Secret(kid, time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(m.sum(key, resp)), nil
+}
+
+//Verify 签名校验方法
+func (m *hmacSigner) Verify(kid KeyID, sign string, req Codec) error {
+	if m.settings.DefaultPolicy == SignPolicyAllow {
+		return nil
+	}
+
+	if "" == kid.ID {
+		return errInvalidHeaderSignKey
+	}
+
+	key, err := m.settings.Secret(kid, time.Now())
+	if err != nil {
+		return err
+	}
+
+	expected, err := hex.DecodeString(sign)
+	if err != nil {
+		return errInvalidSign
+	}
+	if !hmac.Equal(m.sum(key, req), expected) {
+		return errInvalidSign
+	}
+	return nil
+}
+
+func (m *hmacSigner) sum(key string, codec Codec) []byte {
+	mac := hmac.New(m.hash, []byte(key))
+	mac.Write(m.build(codec).Bytes())
+	return mac.Sum(nil)
+}
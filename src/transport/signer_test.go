@@ -1,24 +1,32 @@
 package transport
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/36625090/involution/logical"
+	"github.com/36625090/involution/logical/codes"
+	"github.com/gin-gonic/gin"
+	"github.com/hashicorp/go-hclog"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestMd5Signer_Sign(t *testing.T) {
 
 	settings := Settings{
-		Transport: struct {
-			SignType      string            `json:"sign_type" hcl:"sign_type" default:"md5"`
-			SignKeys      map[string]string `json:"sign_keys" hcl:"sign_keys"`
-			DefaultPolicy SignPolicy        `json:"default_policy" hcl:"default_policy"`
-		}{
-			SignType: "md5",
-			SignKeys: map[string]string{
-				"default": "a1ede45dbcbbf7d9dc64def29f95beda",
-				"user1":   "d41d8cd98f00b204e9800998ecf8427e",
-			},
-			DefaultPolicy: SignPolicyDeny,
+		SignType: "md5",
+		SignKeys: map[string]string{
+			"default": "a1ede45dbcbbf7d9dc64def29f95beda",
+			"user1":   "d41d8cd98f00b204e9800998ecf8427e",
 		},
+		DefaultPolicy: SignPolicyDeny,
 	}
 
 	signer := &signer{}
@@ -28,7 +36,7 @@ func TestMd5Signer_Sign(t *testing.T) {
 
 	req := Request{
 		Method:    "account.user.login",
-		Data:      "{\"name\":\"account\"}",
+		Data:      Data(`"{\"name\":\"account\"}"`),
 		Timestamp: 16123719311,
 		Version:   "1.0.0",
 		Sign:      "JSLJSLJSL1238210131",
@@ -36,9 +44,9 @@ func TestMd5Signer_Sign(t *testing.T) {
 	}
 
 	bs := signer.build(&req)
-	sign, err := md5Sig.Sign("user1", &req)
+	sign, err := md5Sig.Sign(KeyID{ID: "user1"}, &req)
 	t.Log(sign)
-	err = md5Sig.Verify("user1", sign, &req)
+	err = md5Sig.Verify(KeyID{ID: "user1"}, sign, &req)
 	t.Log("verification sign error: ", err)
 
 	res := Response{
@@ -52,5 +60,245 @@ func TestMd5Signer_Sign(t *testing.T) {
 
 	bs = signer.build(&res)
 	t.Log(string(bs.String()))
-	t.Log(md5Sig.Sign(GlobalSignKey, &res))
+	t.Log(md5Sig.Sign(KeyID{ID: GlobalSignKey}, &res))
+}
+
+func TestNewSigners(t *testing.T) {
+	settings := Settings{
+		SignType:  SignTypeHmacSHA256,
+		SignTypes: []string{SignTypeHmacSHA256, SignTypeHmacSHA512, SignTypeMD5},
+		SignKeys: map[string]string{
+			GlobalSignKey: "521004524ef99ad954ad93c3f91c82fd",
+			"user1":       "d41d8cd98f00b204e9800998ecf8427e",
+		},
+		DefaultPolicy: SignPolicyDeny,
+	}
+
+	signers, err := NewSigners(&settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := Request{
+		Method:    "account.user.login",
+		Data:      Data(`"{\"name\":\"account\"}"`),
+		Timestamp: 16123719311,
+		Version:   "1.0.0",
+	}
+	for signType, s := range signers {
+		req.SignType = signType
+		sign, err := s.Sign(KeyID{ID: "user1"}, &req)
+		if err != nil {
+			t.Fatal(signType, err)
+		}
+		if err := s.Verify(KeyID{ID: "user1"}, sign, &req); err != nil {
+			t.Fatal(signType, err)
+		}
+		req.Version = "2.0.0"
+		if err := s.Verify(KeyID{ID: "user1"}, sign, &req); err == nil {
+			t.Fatal(signType, "tampered request verified")
+		}
+		req.Version = "1.0.0"
+	}
+
+	settings.SignTypes = []string{"unknown"}
+	if _, err := NewSigners(&settings); err == nil {
+		t.Fatal("unknown sign type accepted")
+	}
+}
+
+func TestAsymmetricSigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPub, edPri, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := func(der []byte, err error) string {
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.StdEncoding.EncodeToString(der)
+	}
+
+	settings := Settings{
+		SignTypes: []string{SignTypeRSASHA256, SignTypeEd25519},
+		PublicKeys: map[string]string{
+			"rsa-client": encode(x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey), nil),
+			"ed-client":  encode(x509.MarshalPKIXPublicKey(edPub)),
+		},
+		PrivateKeys: map[string]string{
+			SignTypeRSASHA256: encode(x509.MarshalPKCS8PrivateKey(rsaKey)),
+			SignTypeEd25519:   encode(x509.MarshalPKCS8PrivateKey(edPri)),
+		},
+		DefaultPolicy: SignPolicyDeny,
+	}
+	signers, err := NewSigners(&settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := Request{
+		Method:    "account.user.login",
+		Data:      Data(`"{\"name\":\"account\"}"`),
+		Timestamp: 16123719311,
+		Version:   "1.0.0",
+	}
+	clients := map[string]string{SignTypeRSASHA256: "rsa-client", SignTypeEd25519: "ed-client"}
+	for signType, clientId := range clients {
+		req.SignType = signType
+		//客户端与服务端共用测试密钥，服务端私钥签名即模拟客户端签名
+		sign, err := signers[signType].Sign(KeyID{ID: GlobalSignKey}, &req)
+		if err != nil {
+			t.Fatal(signType, err)
+		}
+		if err := signers[signType].Verify(KeyID{ID: clientId}, sign, &req); err != nil {
+			t.Fatal(signType, err)
+		}
+		for other := range clients {
+			if other != signType {
+				if err := signers[other].Verify(KeyID{ID: clientId}, sign, &req); err == nil {
+					t.Fatal(other, "verified with key of", signType)
+				}
+			}
+		}
+	}
+}
+
+func TestReplayGuard(t *testing.T) {
+	guard := &replayGuard{skew: time.Minute, store: NewMemoryNonceStore()}
+	req := Request{Timestamp: time.Now().UnixMilli(), Nonce: "b1946ac92492d2347c6235b4d2611184"}
+	if err := guard.check("user1", &req); err != nil {
+		t.Fatal(err)
+	}
+	if err := guard.check("user1", &req); err != errReplayedRequest {
+		t.Fatal("replayed request accepted:", err)
+	}
+	if err := guard.check("user2", &req); err != nil {
+		t.Fatal(err)
+	}
+
+	req.Nonce = ""
+	if err := guard.check("user1", &req); err != errNonceRequired {
+		t.Fatal("request without nonce accepted:", err)
+	}
+
+	req.Nonce = "591785b794601e212b260e25925636fd"
+	req.Timestamp = time.Now().Add(-time.Minute * 2).UnixMilli()
+	if err := guard.check("user1", &req); err == nil {
+		t.Fatal("expired request accepted")
+	}
+}
+
+func TestSettings_Secret(t *testing.T) {
+	now := time.Now()
+	format := func(d time.Duration) string {
+		return now.Add(d).Format(time.RFC3339)
+	}
+	settings := Settings{
+		SignKeys: map[string]string{"user1": "legacy", "user2": "user2-legacy"},
+		RotatingKeys: []*SignKey{
+			{ClientID: "user1", Version: "v1", Secret: "secret-v1", ExpiresAt: format(time.Hour)},
+			{ClientID: "user1", Version: "v2", Secret: "secret-v2", NotBefore: format(-time.Minute)},
+			{ClientID: "user1", Version: "v3", Secret: "secret-v3", NotBefore: format(time.Hour)},
+			{ClientID: "user1", Version: "v0", Secret: "secret-v0", ExpiresAt: format(-time.Hour)},
+		},
+	}
+	if err := settings.validateKeys(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		kid    KeyID
+		secret string
+	}{
+		{KeyID{ID: "user1", Version: "v1"}, "secret-v1"},
+		{KeyID{ID: "user1", Version: "v2"}, "secret-v2"},
+		{KeyID{ID: "user1"}, "secret-v2"},
+		{KeyID{ID: "user2"}, "user2-legacy"},
+		{KeyID{ID: "user1", Version: "v3"}, ""},
+		{KeyID{ID: "user1", Version: "v0"}, ""},
+		{KeyID{ID: "user3"}, ""},
+	}
+	for _, c := range cases {
+		secret, err := settings.Secret(c.kid, now)
+		if secret != c.secret || (c.secret == "") != (err != nil) {
+			t.Fatal(c.kid, secret, err)
+		}
+	}
+	if v := settings.CurrentVersion("user1", now); v != "v2" {
+		t.Fatal("current version", v)
+	}
+}
+
+func TestCanonicalV2(t *testing.T) {
+	req := &Request{Method: "account.user.home", Data: Data(`{"id":1}`), Timestamp: 1, Version: "1.0", SignType: SignTypeHmacSHA256}
+	canonical := string((&canonicalV2{Codec: req, path: "/api", clientID: "user1"}).Canonical())
+	data, _ := req.Data.Canonical()
+	sum := sha256.Sum256([]byte(data))
+	expected := "sign_version:2:v2\npath:4:/api\nclient_id:5:user1\n" +
+		"body_sha256:64:" + hex.EncodeToString(sum[:]) + "\n" +
+		"encrypt:0:\nencrypted_key:0:\nfiles:0:\nmethod:17:account.user.home\nnonce:0:\n" +
+		"sign_type:11:hmac-sha256\ntimestamp:1:1\nversion:3:1.0\n"
+	if canonical != expected {
+		t.Fatalf("unexpected canonical string:\n%s", canonical)
+	}
+
+	//v1中nonce为空被跳过，v2中空值同样参与签名
+	a := &Request{Method: "a.b.c", Data: Data(`{}`), Timestamp: 1, Version: "1.0", Nonce: "", EncryptedKey: "x"}
+	b := &Request{Method: "a.b.c", Data: Data(`{}`), Timestamp: 1, Version: "1.0", Nonce: "x"}
+	v2 := func(r *Request, path string) string {
+		return string((&canonicalV2{Codec: r, path: path, clientID: "user1"}).Canonical())
+	}
+	if v2(a, "/api") == v2(b, "/api") || v2(a, "/api") == v2(a, "/api/v2") {
+		t.Fatal("canonical v2 is ambiguous")
+	}
+}
+
+func TestTransport_SignVersion(t *testing.T) {
+	settings := &Settings{
+		SignType:      SignTypeHmacSHA256,
+		SignKeys:      map[string]string{GlobalSignKey: "521004524ef99ad954ad93c3f91c82fd", "user1": "d41d8cd98f00b204e9800998ecf8427e", "user2": "c4ca4238a0b923820dcc509a6f75849b"},
+		DefaultPolicy: SignPolicyDeny,
+		SignVersions:  map[string]string{"user2": SignVersion2},
+	}
+	tr, err := NewTransport(gin.New(), settings, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.AddHandle("/api", logical.HttpMethodPOST, func(c *Context) error {
+		c.WithContent("ok")
+		return nil
+	})
+	signer := NewHmacSHA256Signer(settings)
+
+	post := func(client string, v2 bool) (*Response, *httptest.ResponseRecorder) {
+		req := Request{Method: "account.user.home", Data: Data(`{}`), Timestamp: 1, Version: "1.0", SignType: SignTypeHmacSHA256}
+		var codec Codec = &req
+		if v2 {
+			codec = &canonicalV2{Codec: &req, path: "/api", clientID: client}
+		}
+		req.Sign, _ = signer.Sign(KeyID{ID: client}, codec)
+		var resp Response
+		w := serveTest(t, tr, "/api", req, http.Header{logical.HeaderClientIDKey.String(): {client}}, &resp)
+		return &resp, w
+	}
+
+	if resp, w := post("user1", false); resp.Code != 0 || w.Header().Get(logical.HeaderSignVersionKey.String()) != SignVersion1 {
+		t.Fatal("v1 client rejected", resp.Code, resp.Message)
+	}
+	if resp, _ := post("user2", false); resp.Code != codes.CodeInvalidSignature.Int() {
+		t.Fatal("v2 client accepted a v1 signature", resp.Code)
+	}
+	resp, w := post("user2", true)
+	if resp.Code != 0 || w.Header().Get(logical.HeaderSignVersionKey.String()) != SignVersion2 {
+		t.Fatal("v2 client rejected", resp.Code, resp.Message)
+	}
+	sign := resp.Sign
+	resp.Sign = ""
+	if err := signer.Verify(KeyID{ID: GlobalSignKey}, sign, &canonicalV2{Codec: resp, path: "/api", clientID: "user2"}); err != nil {
+		t.Fatal("v2 response signature", err)
+	}
 }
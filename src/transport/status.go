@@ -0,0 +1,58 @@
+package transport
+
+import (
+	"fmt"
+	"github.com/36625090/involution/logical/codes"
+	"net/http"
+)
+
+//StatusRule 业务状态码区间[From, To]到HTTP状态码的映射，To为0时只匹配From
+type StatusRule struct {
+	From   int `json:"from" hcl:"from"`
+	To     int `json:"to" hcl:"to"`
+	Status int `json:"status" hcl:"status"`
+}
+
+func (r *StatusRule) match(code int) bool {
+	if r.To == 0 {
+		return code == r.From
+	}
+	return code >= r.From && code <= r.To
+}
+
+//defaultStatusRules 默认的HTTP状态码映射，未匹配的业务错误返回200
+var defaultStatusRules = []*StatusRule{
+	{From: codes.CodeUnauthorized.Int(), Status: http.StatusUnauthorized},
+	{From: codes.CodeInvalidSignature.Int(), Status: http.StatusForbidden},
+	{From: codes.CodeEndpointNotFound.Int(), To: codes.CodeOperationNotFound.Int(), Status: http.StatusNotFound},
+	{From: codes.CodeServerInternalError.Int(), Status: http.StatusInternalServerError},
+	{From: codes.CodeHandleRequest.Int(), Status: http.StatusInternalServerError},
+}
+
+//HTTPStatus 响应业务状态码对应的HTTP状态码
+//status_always_ok为true时始终返回200，兼容按业务状态码处理错误的旧客户端；配置的映射优先于默认映射
+func (m *Transport) HTTPStatus(code int) int {
+	if code == codes.CodeSuccess.Int() || m.settings.StatusAlwaysOK {
+		return http.StatusOK
+	}
+	for _, rules := range [][]*StatusRule{m.settings.StatusMapping, defaultStatusRules} {
+		for _, rule := range rules {
+			if rule.match(code) {
+				return rule.Status
+			}
+		}
+	}
+	return http.StatusOK
+}
+
+func (s *Settings) validateStatusMapping() error {
+	for _, rule := range s.StatusMapping {
+		if rule.To != 0 && rule.To < rule.From {
+			return fmt.Errorf("status mapping: invalid code range %d-%d", rule.From, rule.To)
+		}
+		if http.StatusText(rule.Status) == "" {
+			return fmt.Errorf("status mapping: invalid http status %d", rule.Status)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package transport
+
+import (
+	"errors"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"mime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	MIMEEventStream = "text/event-stream"
+
+	StreamEventProgress = "progress"
+	StreamEventData     = "data"
+	//StreamEventResponse 最后一帧，内容为签名后的Response
+	StreamEventResponse = "response"
+)
+
+var errStreamClosed = errors.New("stream closed by client")
+
+var errStreamEncrypted = errors.New("encrypted request does not support streaming")
+
+//IsStreamRequest 客户端是否请求SSE流式响应
+func IsStreamRequest(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && mediaType == MIMEEventStream {
+			return true
+		}
+	}
+	return false
+}
+
+//Progress 进度事件的数据结构
+type Progress struct {
+	Percent int    `json:"percent"`
+	Message string `json:"message"`
+}
+
+//sseWriter 基于Server-Sent Events的logical.StreamWriter实现
+type sseWriter struct {
+	sync.Mutex
+	ctx *gin.Context
+	id  int
+}
+
+func newSSEWriter(ctx *gin.Context) *sseWriter {
+	header := ctx.Writer.Header()
+	header.Set("Content-Type", MIMEEventStream)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	return &sseWriter{ctx: ctx}
+}
+
+func (w *sseWriter) Progress(percent int, message string) error {
+	return w.Send(StreamEventProgress, &Progress{Percent: percent, Message: message})
+}
+
+func (w *sseWriter) Send(event string, data interface{}) error {
+	if event == "" {
+		event = StreamEventData
+	}
+	return w.write(event, data)
+}
+
+func (w *sseWriter) write(event string, data interface{}) error {
+	w.Lock()
+	defer w.Unlock()
+	if err := w.ctx.Request.Context().Err(); err != nil {
+		return errStreamClosed
+	}
+	w.id++
+	w.ctx.Status(200)
+	if err := sse.Encode(w.ctx.Writer, sse.Event{Event: event, Id: strconv.Itoa(w.id), Data: data}); err != nil {
+		return err
+	}
+	w.ctx.Writer.Flush()
+	return nil
+}
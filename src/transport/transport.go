@@ -1,9 +1,11 @@
 package transport
 
 import (
+	"errors"
 	"fmt"
 	"github.com/36625090/involution/logical"
 	"github.com/36625090/involution/logical/codes"
+	"github.com/36625090/involution/tracing"
 	"github.com/gin-gonic/gin"
 	"github.com/hashicorp/go-hclog"
 	"runtime/debug"
@@ -14,18 +16,52 @@ import (
 //Transport 继承了gin实现的服务接口
 type Transport struct {
 	*gin.Engine
-	logger hclog.Logger
-	signer Signer
-	pool   sync.Pool
+	logger    hclog.Logger
+	settings  *Settings
+	signers   map[string]Signer
+	replay    *replayGuard
+	encryptor *encryptor
+	//idempotency 幂等请求，参考SetIdempotency
+	idempotency *idempotency
+	middlewares []Middleware
+	pool        sync.Pool
 }
 
 type Handle func(c *Context) error
 
-func NewTransport(en *gin.Engine, settings *Settings, logger hclog.Logger) *Transport {
+func NewTransport(en *gin.Engine, settings *Settings, logger hclog.Logger) (*Transport, error) {
+	if nil == settings {
+		settings = &Settings{}
+	}
+	if err := settings.validateKeys(); err != nil {
+		return nil, err
+	}
+	if err := settings.validateStatusMapping(); err != nil {
+		return nil, err
+	}
+	if err := settings.validateSignVersions(); err != nil {
+		return nil, err
+	}
+	signers, err := NewSigners(settings)
+	if err != nil {
+		return nil, err
+	}
+	encryptor, err := newEncryptor(settings)
+	if err != nil {
+		return nil, err
+	}
 	transport := &Transport{
-		Engine: en,
-		logger: logger.Named("transport"),
-		signer: NewMD5Signer(settings),
+		Engine:    en,
+		logger:    logger.Named("transport"),
+		settings:  settings,
+		signers:   signers,
+		encryptor: encryptor,
+	}
+	if settings.TimestampSkew > 0 {
+		transport.replay = &replayGuard{
+			skew:  time.Duration(settings.TimestampSkew) * time.Second,
+			store: NewMemoryNonceStore(),
+		}
 	}
 	transport.pool.New = func() interface{} {
 		ctx :=  new(Context)
@@ -38,65 +74,318 @@ func NewTransport(en *gin.Engine, settings *Settings, logger hclog.Logger) *Tran
 		return ctx
 	}
 
-	return transport
+	return transport, nil
+}
+
+//SetNonceStore 设置防重放的nonce存储，未开启防重放时忽略
+func (m *Transport) SetNonceStore(store NonceStore) {
+	if m.replay != nil {
+		m.replay.store = store
+	}
+}
+
+//responseKeyID 响应签名使用的密钥，按配置使用全局密钥或客户端请求使用的密钥
+func (m *Transport) responseKeyID(kid KeyID) KeyID {
+	if m.settings.ResponseSignKey != ResponseSignKeyClient {
+		kid = KeyID{ID: GlobalSignKey}
+	}
+	if kid.Version == "" {
+		kid.Version = m.settings.CurrentVersion(kid.ID, time.Now())
+	}
+	return kid
+}
+
+//signer 根据客户端请求的签名算法获取签名器
+func (m *Transport) signer(signType string) (Signer, error) {
+	s, ok := m.signers[signType]
+	if !ok {
+		return nil, fmt.Errorf("%s: %s, accepted: %v", errUnsupportedSignType, signType, m.settings.AcceptedSignTypes())
+	}
+	return s, nil
 }
 
 //AddHandle 添加路径handlerFunc
 //path 绝对路径
+//请求体为JSON数组时按批量请求处理，参考serveBatch
+//multipart/form-data请求的信封位于envelope字段，其他文件字段参考readMultipart
 func (m *Transport) AddHandle(absolutePath string, method logical.HttpMethod, handle Handle) {
 	m.logger.Info("initialize handle", "path", absolutePath, "method", method)
 	m.Engine.Handle(string(method), absolutePath, func(gCtx *gin.Context) {
 		ctx := NewContext(gCtx)
-		ctx.ctx = gCtx
-		ctx.response.TraceID = ctx.GetTraceID()
-
-		defer func() {
-			m.pool.Put(ctx)
-			if r := recover(); r != nil {
-				m.logger.Error("received panic", "err", r, "stack", string(debug.Stack()))
-				ctx.WithCode(codes.CodeHandleRequest).
-					WithMessage(fmt.Sprintf("%v", r))
-				ctx.write()
+		if isMultipart(gCtx.ContentType()) {
+			err := m.readMultipart(ctx)
+			defer removeUploads(ctx.files)
+			if err != nil {
+				m.logger.Error("read multipart request", "path", gCtx.Request.RequestURI, "err", err)
+				ctx.response.TraceID = ctx.GetTraceID()
+				ctx.WithCode(codes.CodeInvalidUpload).WithError(err)
+				ctx.write(m.HTTPStatus(ctx.response.Code))
+				return
+			}
+		} else {
+			body, err := gCtx.GetRawData()
+			if err != nil {
+				m.logger.Error("read request body", "path", gCtx.Request.RequestURI, "err", err)
+				ctx.response.TraceID = ctx.GetTraceID()
+				ctx.WithCode(codes.CodeReadRequestBody).WithError(err)
+				ctx.write(m.HTTPStatus(ctx.response.Code))
+				return
+			}
+			if isBatch(body) && requestEncoding(gCtx.ContentType()) == (jsonEncoding{}) {
+				m.serveBatch(gCtx, body, handle)
+				return
 			}
-		}()
+			ctx.body = body
+		}
+
+		if IsStreamRequest(gCtx.GetHeader("Accept")) {
+			ctx.stream = newSSEWriter(gCtx)
+		} else {
+			ctx.downloadable = true
+		}
+		m.serve(ctx, handle)
+		ctx.write(m.HTTPStatus(ctx.response.Code))
+		m.pool.Put(ctx)
+	})
+}
+
+//ServeEnvelope 处理单个请求信封并返回签名后的响应，用于HTTP请求以外的通道(如WebSocket)
+//gCtx 提供客户端ID、签名密钥版本、令牌等请求头，body按gCtx的Content-Type解码，默认JSON
+func (m *Transport) ServeEnvelope(gCtx *gin.Context, body []byte, handle Handle) *Response {
+	ctx := NewContext(gCtx)
+	ctx.body = body
+	ctx.shared = true
+	m.serve(ctx, handle)
+	return ctx.response
+}
+
+//ServeOptions 处理由其他协议转换得到的请求的选项，参考ServeRequest
+type ServeOptions struct {
+	//Shared 请求头由多个请求共用(如JSON-RPC批量调用)，不使用请求头中的幂等键
+	Shared bool
+	//Unsigned 跳过验签和防重放，响应照常签名，仅用于后端声明允许未签名调用的操作
+	Unsigned bool
+	//SignData 参与验签的data，为空时使用请求的data
+	SignData Data
+}
+
+//ServeRequest 处理由其他协议(如JSON-RPC)转换得到的请求并返回签名后的响应
+//req的签名字段由该协议携带，sign_type为空时使用服务端的首选签名算法；
+//与请求信封相同，执行验签、防重放、解密、中间件、业务处理及响应签名，不支持上传和下载，参考HandleRequest
+func (m *Transport) ServeRequest(gCtx *gin.Context, req *Request, opts *ServeOptions, handle Handle) *Response {
+	ctx := m.requestContext(gCtx, req, opts)
+	m.serve(ctx, handle)
+	return ctx.response
+}
+
+//HandleRequest 与ServeRequest相同，并按请求的Accept输出响应，支持下载，用于直接响应HTTP请求的协议(如RESTful路由)
+func (m *Transport) HandleRequest(gCtx *gin.Context, req *Request, opts *ServeOptions, handle Handle) {
+	ctx := m.requestContext(gCtx, req, opts)
+	ctx.downloadable = true
+	m.serve(ctx, handle)
+	ctx.write(m.HTTPStatus(ctx.response.Code))
+}
+
+func (m *Transport) requestContext(gCtx *gin.Context, req *Request, opts *ServeOptions) *Context {
+	if nil == opts {
+		opts = &ServeOptions{}
+	}
+	if req.SignType == "" {
+		req.SignType = m.settings.AcceptedSignTypes()[0]
+	}
+	ctx := NewContext(gCtx)
+	ctx.request = req
+	ctx.bound = true
+	ctx.shared = opts.Shared
+	ctx.unsigned = opts.Unsigned
+	ctx.signData = opts.SignData
+	return ctx
+}
+
+//serve 处理单个请求信封：绑定、验签、防重放、业务处理及响应签名，结果写入ctx.response
+func (m *Transport) serve(ctx *Context, handle Handle) {
+	ctx.response.TraceID = ctx.GetTraceID()
+	logger := m.logger.With("trace-id", ctx.response.TraceID)
+
+	//每个请求信封一个span，绑定后以method命名
+	traceCtx, span := tracing.Start(ctx.RawRequest().Context(), "transport.envelope")
+	ctx.traceCtx = traceCtx
+	defer func() {
+		span.SetAttributes(tracing.AttrCode.Int(ctx.response.Code))
+		var err error
+		if ctx.response.Code != codes.CodeSuccess.Int() {
+			err = errors.New(ctx.response.Message)
+		}
+		tracing.End(span, err)
+	}()
+	defer func() {
+		for _, fn := range ctx.onSigned {
+			fn(ctx)
+		}
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("received panic", "err", r, "stack", string(debug.Stack()))
+			ctx.WithCode(codes.CodeHandleRequest).
+				WithMessage(fmt.Sprintf("%v", r))
+		}
+	}()
+	//未输出的下载内容需要关闭
+	defer func() {
+		if download, ok := ctx.response.Content.(*logical.Download); ok {
+			download.Close()
+			ctx.response.Content = nil
+		}
+	}()
 
-		if err := ctx.ShouldBindJSON(); err != nil {
-			m.logger.Error("should not bind JSON", "path", ctx.RawRequest().RequestURI, "err", err)
+	if !ctx.bound {
+		_, bindSpan := tracing.Start(traceCtx, "transport.bind")
+		err := ctx.ShouldBind()
+		tracing.End(bindSpan, err)
+		if err != nil {
+			logger.Error("should not bind request", "path", ctx.RawRequest().RequestURI, "err", err)
 
 			ctx.WithCode(codes.CodeBindRequestData).
-				WithMessage(err.Error()).write()
+				WithMessage(err.Error())
 			return
 		}
+	}
+	span.SetName(ctx.request.Method)
+	span.SetAttributes(tracing.Method(ctx.request.Method)...)
+	span.SetAttributes(tracing.AttrClientID.String(ctx.GetClientID()))
 
-		if err := m.signer.Verify(ctx.GetClientID(), ctx.request.Sign, ctx.request); err != nil {
-			m.logger.Error("verify request sign error",
+	//验签及防重放
+	_, verifySpan := tracing.Start(traceCtx, "transport.verify")
+	signer, err := m.signer(ctx.request.SignType)
+	if err != nil {
+		logger.Error("verify request sign type error",
+			"path", ctx.RawRequest().RequestURI,
+			"client-id", ctx.GetClientID(),
+			"sign_type", ctx.request.SignType,
+			"err", err)
+		ctx.WithCode(codes.CodeInvalidSignature).WithError(err)
+		tracing.End(verifySpan, err)
+		return
+	}
+
+	kid := KeyID{ID: ctx.GetClientID(), Version: ctx.GetSignKeyVersion()}
+	signVersion := m.settings.SignVersion(kid.ID)
+	if !ctx.unsigned {
+		if err := signer.Verify(kid, ctx.request.Sign, ctx.canonical(signVersion, ctx.signedRequest())); err != nil {
+			logger.Error("verify request sign error",
 				"path", ctx.RawRequest().RequestURI,
 				"client-id", ctx.GetClientID(),
+				"key-version", kid.Version,
 				"sign", ctx.request.Sign,
 				"err", err)
 			ctx.WithCode(codes.CodeInvalidSignature).
-				WithMessage("verify request sign error, " + err.Error()+" : "+ ctx.Request().Sign).write()
+				WithMessage("verify request sign error, " + err.Error() + " : " + ctx.Request().Sign)
+			tracing.End(verifySpan, err)
 			return
 		}
 
-		err := handle(ctx)
-		if nil != err {
-			m.logger.Error("handle request error",
-				"path", ctx.RawRequest().RequestURI, "err", err)
-			ctx.write()
+		if m.replay != nil {
+			if err := m.replay.check(ctx.GetClientID(), ctx.request); err != nil {
+				logger.Error("replay check error",
+					"path", ctx.RawRequest().RequestURI,
+					"client-id", ctx.GetClientID(),
+					"timestamp", ctx.request.Timestamp,
+					"nonce", ctx.request.Nonce,
+					"err", err)
+				ctx.WithCode(codes.CodeReplayedRequest).WithError(err)
+				tracing.End(verifySpan, err)
+				return
+			}
+		}
+	}
+	tracing.End(verifySpan, nil)
+
+	if err := verifyUploads(ctx.request, ctx.files); err != nil {
+		logger.Error("verify upload error",
+			"path", ctx.RawRequest().RequestURI,
+			"client-id", ctx.GetClientID(),
+			"err", err)
+		ctx.WithCode(codes.CodeInvalidUpload).WithError(err)
+		return
+	}
+
+	encrypt := ctx.request.Encrypt
+	//SSE的进度和部分结果不加密，加密的请求不支持流式响应
+	if encrypt != "" && ctx.stream != nil {
+		ctx.WithCode(codes.CodeInvalidEncryption).WithError(errStreamEncrypted)
+		return
+	}
+	if m.encryptor != nil {
+		if err := m.encryptor.decryptRequest(ctx.GetClientID(), ctx.request); err != nil {
+			logger.Error("decrypt request error",
+				"path", ctx.RawRequest().RequestURI,
+				"client-id", ctx.GetClientID(),
+				"encrypt", encrypt,
+				"err", err)
+			ctx.WithCode(codes.CodeInvalidEncryption).WithError(err)
 			return
 		}
+	} else if encrypt != "" {
+		ctx.WithCode(codes.CodeInvalidEncryption).WithError(errUnsupportedEncrypt)
+		return
+	}
 
-		sign, err := m.signer.Sign(GlobalSignKey, ctx.response)
-		if err != nil {
-			ctx.WithCode(codes.CodeInvalidSignature).WithMessage(err.Error()).write()
+	err = m.chain(handle)(ctx)
+	if nil != err {
+		logger.Error("handle request error",
+			"path", ctx.RawRequest().RequestURI, "err", err)
+		return
+	}
+	//复用的响应已加密和签名
+	if ctx.reused {
+		return
+	}
+
+	//下载响应的content替换为文件信息，输出文件内容后签名
+	if download, ok := ctx.response.Content.(*logical.Download); ok {
+		if err := m.prepareDownload(ctx, encrypt, download); err != nil {
+			logger.Error("prepare download error",
+				"path", ctx.RawRequest().RequestURI,
+				"client-id", ctx.GetClientID(),
+				"err", err)
+			code := codes.CodeServerInternalError
+			if err == errDownloadUnsupported || err == errDownloadEncrypted {
+				code = codes.CodeDownloadUnsupported
+			}
+			ctx.WithCode(code).WithError(err).WithContent(nil)
+			return
+		}
+	}
+
+	//加密的请求响应同样加密，签名覆盖密文
+	if m.encryptor != nil {
+		if err := m.encryptor.encryptResponse(ctx.GetClientID(), encrypt, ctx.response); err != nil {
+			logger.Error("encrypt response error",
+				"path", ctx.RawRequest().RequestURI,
+				"client-id", ctx.GetClientID(),
+				"err", err)
+			ctx.WithCode(codes.CodeInvalidEncryption).WithError(err).WithContent(nil)
 			return
 		}
+	}
 
+	//响应使用与请求相同的签名算法
+	respKid := m.responseKeyID(kid)
+	if ctx.download != nil {
+		ctx.download.sign = func(resp *Response) (string, error) {
+			return signer.Sign(respKid, ctx.canonical(signVersion, resp))
+		}
+	} else {
+		sign, err := signer.Sign(respKid, ctx.canonical(signVersion, ctx.response))
+		if err != nil {
+			ctx.WithCode(codes.CodeInvalidSignature).WithMessage(err.Error())
+			return
+		}
 		ctx.WithSign(sign)
-		ctx.write()
+	}
 
-	})
+	ctx.signKeyVersion = respKid.Version
+	ctx.signVersion = signVersion
 }
 
 func (m *Transport) Router() gin.IRouter {
@@ -0,0 +1,441 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/36625090/involution/logical"
+	"github.com/36625090/involution/logical/codes"
+	"github.com/gin-gonic/gin"
+	"github.com/hashicorp/go-hclog"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func newTestTransport(t *testing.T, settings *Settings) *Transport {
+	gin.SetMode(gin.ReleaseMode)
+	tr, err := NewTransport(gin.New(), settings, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.AddHandle("/api", logical.HttpMethodPOST, func(c *Context) error {
+		if c.Request().Operation() == "fail" {
+			c.WithCode(codes.CodeFailure).WithMessage("failed")
+			return errors.New("failed")
+		}
+		c.WithContent(c.Request().Method)
+		return nil
+	})
+	return tr
+}
+
+//serveTest 向tr发送JSON编码的body，out不为nil时将响应体解码到out，返回响应记录
+func serveTest(t *testing.T, tr *Transport, path string, body interface{}, header http.Header, out interface{}) *httptest.ResponseRecorder {
+	bs, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(bs))
+	req.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, req)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+	}
+	return w
+}
+
+func TestTransport_Batch(t *testing.T) {
+	tr := newTestTransport(t, &Settings{DefaultPolicy: SignPolicyAllow, BatchConcurrency: 1})
+
+	envelope := func(method string) Request {
+		return Request{Method: method, Data: Data(`{}`), Timestamp: 1, Version: "1.0", Sign: "-", SignType: SignTypeMD5}
+	}
+	body, _ := json.Marshal([]Request{
+		envelope("account.user.home"),
+		envelope("account.user.fail"),
+		envelope("account.user.login"),
+	})
+
+	post := func(abort string) []*Response {
+		req := httptest.NewRequest(http.MethodPost, "/api", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(logical.HeaderBatchAbortKey.String(), abort)
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, req)
+		var responses []*Response
+		if err := json.Unmarshal(w.Body.Bytes(), &responses); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		if len(responses) != 3 {
+			t.Fatal("unexpected responses", w.Body.String())
+		}
+		return responses
+	}
+
+	responses := post("false")
+	if responses[0].Content != "account.user.home" || responses[2].Content != "account.user.login" ||
+		responses[1].Code != codes.CodeFailure.Int() {
+		t.Fatal("unexpected batch result", responses[0], responses[1], responses[2])
+	}
+
+	responses = post("true")
+	if responses[0].Code != 0 || responses[2].Code != codes.CodeBatchAborted.Int() {
+		t.Fatal("batch not aborted", responses[0], responses[1], responses[2])
+	}
+}
+
+func TestTransport_ReadBodyError(t *testing.T) {
+	tr := newTestTransport(t, &Settings{DefaultPolicy: SignPolicyAllow})
+
+	req := httptest.NewRequest(http.MethodPost, "/api", iotest.ErrReader(io.ErrUnexpectedEOF))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, req)
+
+	var resp Response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+	if resp.Code != codes.CodeReadRequestBody.Int() || !strings.Contains(resp.Message, io.ErrUnexpectedEOF.Error()) {
+		t.Fatal("unexpected response", w.Body.String())
+	}
+}
+
+func TestTransport_Stream(t *testing.T) {
+	tr := newTestTransport(t, &Settings{DefaultPolicy: SignPolicyAllow})
+	tr.AddHandle("/stream", logical.HttpMethodPOST, func(c *Context) error {
+		args, err := c.DecodeArgs()
+		if err != nil {
+			return err
+		}
+		if args.Stream == nil {
+			t.Fatal("stream writer not attached")
+		}
+		_ = args.Stream.Progress(50, "half")
+		_ = args.Stream.Send("", "partial")
+		c.WithContent("done")
+		return nil
+	})
+
+	body, _ := json.Marshal(Request{Method: "account.user.home", Data: Data(`{}`), Timestamp: 1, Version: "1.0", Sign: "-", SignType: SignTypeMD5})
+	req := httptest.NewRequest(http.MethodPost, "/stream", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", MIMEEventStream)
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, req)
+
+	if w.Header().Get("Content-Type") != MIMEEventStream {
+		t.Fatal("unexpected content type", w.Header().Get("Content-Type"))
+	}
+	out := w.Body.String()
+	for _, frame := range []string{
+		"id:1\nevent:progress\ndata:{\"percent\":50,\"message\":\"half\"}\n\n",
+		"id:2\nevent:data\ndata:partial\n\n",
+		"id:3\nevent:response\n",
+	} {
+		if !bytes.Contains([]byte(out), []byte(frame)) {
+			t.Fatalf("frame %q not found in %q", frame, out)
+		}
+	}
+
+	//加密的请求不输出明文的进度帧
+	body, _ = json.Marshal(Request{Method: "account.user.home", Data: Data(`"ciphertext"`), Timestamp: 1, Version: "1.0", Sign: "-", SignType: SignTypeMD5,
+		Encrypt: EncryptPSK, EncryptedKey: "key"})
+	req = httptest.NewRequest(http.MethodPost, "/stream", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", MIMEEventStream)
+	w = httptest.NewRecorder()
+	tr.ServeHTTP(w, req)
+	if out = w.Body.String(); strings.Contains(out, "event:progress") ||
+		!strings.Contains(out, fmt.Sprintf(`"code":%d`, codes.CodeInvalidEncryption)) {
+		t.Fatal("encrypted stream request not rejected", out)
+	}
+}
+
+func TestTransport_Upload(t *testing.T) {
+	tr := newTestTransport(t, &Settings{DefaultPolicy: SignPolicyAllow})
+	tr.AddHandle("/upload", logical.HttpMethodPOST, func(c *Context) error {
+		args, err := c.DecodeArgs()
+		if err != nil {
+			return err
+		}
+		file := args.File("avatar")
+		if file == nil {
+			t.Fatal("upload file not attached")
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		content, _ := io.ReadAll(reader)
+		c.WithContent(file.Filename + ":" + string(content))
+		return nil
+	})
+
+	content := []byte("avatar-content")
+	sum := sha256.Sum256(content)
+	post := func(hash string) *Response {
+		envelope, _ := json.Marshal(Request{Method: "account.user.avatar", Data: Data(`{}`), Timestamp: 1, Version: "1.0",
+			Sign: "-", SignType: SignTypeMD5, Files: map[string]string{"avatar": hash}})
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		_ = writer.WriteField(UploadEnvelopeField, string(envelope))
+		part, _ := writer.CreateFormFile("avatar", "avatar.png")
+		_, _ = part.Write(content)
+		_ = writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, "/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, req)
+		var resp Response
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		return &resp
+	}
+
+	if resp := post(hex.EncodeToString(sum[:])); resp.Code != 0 || resp.Content != "avatar.png:avatar-content" {
+		t.Fatal("unexpected upload result", resp.Code, resp.Message, resp.Content)
+	}
+	if resp := post(strings.Repeat("0", 64)); resp.Code != codes.CodeInvalidUpload.Int() {
+		t.Fatal("hash mismatch not rejected", resp.Code, resp.Message)
+	}
+}
+
+func TestTransport_Download(t *testing.T) {
+	settings := &Settings{
+		SignType:      SignTypeMD5,
+		SignKeys:      map[string]string{GlobalSignKey: "521004524ef99ad954ad93c3f91c82fd", "user1": "d41d8cd98f00b204e9800998ecf8427e"},
+		DefaultPolicy: SignPolicyDeny,
+	}
+	tr := newTestTransport(t, settings)
+	tr.AddHandle("/download", logical.HttpMethodPOST, func(c *Context) error {
+		c.WithContent(logical.NewDownload("invoice 1.pdf", "application/pdf", io.NopCloser(strings.NewReader("%PDF-1.4"))))
+		return nil
+	})
+
+	signer := NewMD5Signer(settings)
+	envelope := Request{Method: "account.invoice.pdf", Data: Data(`{}`), Timestamp: 1, Version: "1.0", SignType: SignTypeMD5}
+	envelope.Sign, _ = signer.Sign(KeyID{ID: "user1"}, &envelope)
+	body, _ := json.Marshal(envelope)
+	req := httptest.NewRequest(http.MethodPost, "/download", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(logical.HeaderClientIDKey.String(), "user1")
+	req.Header.Set(logical.HeaderTraceIDKey.String(), "trace-1")
+	w := httptest.NewRecorder()
+	tr.ServeHTTP(w, req)
+
+	if w.Body.String() != "%PDF-1.4" || w.Header().Get("Content-Type") != "application/pdf" {
+		t.Fatal("unexpected download body", w.Header(), w.Body.String())
+	}
+	if w.Header().Get("Content-Disposition") != `attachment; filename="invoice 1.pdf"` {
+		t.Fatal("unexpected content disposition", w.Header().Get("Content-Disposition"))
+	}
+
+	//摘要和签名在文件内容之后通过trailer返回
+	trailer := w.Result().Trailer
+	sum := sha256.Sum256([]byte("%PDF-1.4"))
+	timestamp, _ := strconv.ParseInt(w.Header().Get(logical.HeaderTimestampKey.String()), 10, 64)
+	resp := &Response{
+		Content: &DownloadInfo{Filename: "invoice 1.pdf", ContentType: "application/pdf", Size: int64(w.Body.Len()),
+			SHA256: trailer.Get(logical.HeaderContentSHA256Key.String())},
+		TraceID:   w.Header().Get(logical.HeaderTraceIDKey.String()),
+		Timestamp: timestamp,
+	}
+	if resp.TraceID != "trace-1" || resp.Content.(*DownloadInfo).SHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatal("unexpected download trailer", w.Header(), trailer)
+	}
+	if err := signer.Verify(KeyID{ID: GlobalSignKey}, trailer.Get(logical.HeaderSignKey.String()), resp); err != nil {
+		t.Fatal("download signature", err)
+	}
+}
+
+func TestTransport_Idempotent(t *testing.T) {
+	tr := newTestTransport(t, &Settings{DefaultPolicy: SignPolicyAllow})
+	tr.SetIdempotency(&IdempotencySettings{Wait: 5}, nil)
+
+	var calls int32
+	tr.AddHandle("/pay", logical.HttpMethodPOST, func(c *Context) error {
+		reused, err := tr.Idempotent(c, "user1::"+c.GetIdempotencyKey())
+		if err != nil {
+			c.WithCode(codes.CodeIdempotencyConflict).WithError(err)
+			return err
+		}
+		if reused {
+			return nil
+		}
+		time.Sleep(50 * time.Millisecond)
+		c.WithContent(map[string]interface{}{"order": atomic.AddInt32(&calls, 1)})
+		return nil
+	})
+
+	post := func(data string) *Response {
+		body, _ := json.Marshal(Request{Method: "account.order.pay", Data: Data(data), Timestamp: 1, Version: "1.0", Sign: "-", SignType: SignTypeMD5})
+		req := httptest.NewRequest(http.MethodPost, "/pay", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(logical.HeaderIdempotencyKey.String(), "key-1")
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, req)
+		var resp Response
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		return &resp
+	}
+
+	responses := make([]*Response, 4)
+	var wg sync.WaitGroup
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i] = post(`{"amount":1}`)
+		}(i)
+	}
+	wg.Wait()
+
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Fatal("handler executed more than once", calls)
+	}
+	for _, resp := range responses {
+		if resp.Code != 0 || resp.Timestamp != responses[0].Timestamp {
+			t.Fatal("duplicate did not reuse the first response", resp, responses[0])
+		}
+	}
+	if resp := post(`{"amount":2}`); resp.Code != codes.CodeIdempotencyConflict.Int() {
+		t.Fatal("reused key with different data not rejected", resp.Code, resp.Message)
+	}
+}
+
+func TestTransport_IdempotentTransient(t *testing.T) {
+	tr := newTestTransport(t, &Settings{DefaultPolicy: SignPolicyAllow})
+	tr.SetIdempotency(&IdempotencySettings{}, nil)
+
+	results := []codes.ReturnCode{codes.CodeServerInternalError, codes.CodeUnauthorized, 5001}
+	var calls int
+	tr.AddHandle("/pay", logical.HttpMethodPOST, func(c *Context) error {
+		if reused, err := tr.Idempotent(c, "user1::"+c.GetIdempotencyKey()); err != nil || reused {
+			return err
+		}
+		c.WithCode(results[calls])
+		calls++
+		return nil
+	})
+
+	post := func() *Response {
+		body, _ := json.Marshal(Request{Method: "account.order.pay", Data: Data(`{}`), Timestamp: 1, Version: "1.0", Sign: "-", SignType: SignTypeMD5})
+		req := httptest.NewRequest(http.MethodPost, "/pay", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(logical.HeaderIdempotencyKey.String(), "key-1")
+		w := httptest.NewRecorder()
+		tr.ServeHTTP(w, req)
+		var resp Response
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err, w.Body.String())
+		}
+		return &resp
+	}
+
+	//系统和鉴权失败不保存，确定性的业务失败被复用
+	for i, code := range []codes.ReturnCode{codes.CodeServerInternalError, codes.CodeUnauthorized, 5001, 5001} {
+		if resp := post(); resp.Code != code.Int() {
+			t.Fatal("unexpected response", i, resp.Code)
+		}
+	}
+	if calls != 3 {
+		t.Fatal("unexpected handler calls", calls)
+	}
+}
+
+func TestTransport_HTTPStatus(t *testing.T) {
+	tr := newTestTransport(t, &Settings{
+		DefaultPolicy: SignPolicyAllow,
+		StatusMapping: []*StatusRule{{From: 3000, To: 3999, Status: http.StatusServiceUnavailable}},
+	})
+	for code, status := range map[codes.ReturnCode]int{
+		codes.CodeSuccess:             http.StatusOK,
+		codes.CodeFailure:             http.StatusOK,
+		codes.CodeUnauthorized:        http.StatusUnauthorized,
+		codes.CodeInvalidSignature:    http.StatusForbidden,
+		codes.CodeOperationNotFound:   http.StatusNotFound,
+		codes.CodeServerInternalError: http.StatusInternalServerError,
+		codes.CodeServiceException:    http.StatusServiceUnavailable,
+	} {
+		if got := tr.HTTPStatus(code.Int()); got != status {
+			t.Fatalf("code %d mapped to %d, expected %d", code, got, status)
+		}
+	}
+
+	post := func(tr *Transport) int {
+		return serveTest(t, tr, "/api", Request{Method: "account.user.home", Data: Data(`{}`), Timestamp: 1, Version: "1.0", Sign: "-", SignType: "unknown"}, nil, nil).Code
+	}
+	if status := post(tr); status != http.StatusForbidden {
+		t.Fatal("invalid signature should return 403", status)
+	}
+	if status := post(newTestTransport(t, &Settings{DefaultPolicy: SignPolicyAllow, StatusAlwaysOK: true})); status != http.StatusOK {
+		t.Fatal("status_always_ok should return 200", status)
+	}
+}
+
+func TestTransport_Middleware(t *testing.T) {
+	tr := newTestTransport(t, &Settings{DefaultPolicy: SignPolicyAllow})
+	var order []string
+	tr.AddMiddleware(
+		func(next Handle) Handle {
+			return func(c *Context) error {
+				order = append(order, "outer:"+c.Request().Method)
+				err := next(c)
+				order = append(order, "outer:"+fmt.Sprint(c.Response().Content))
+				return err
+			}
+		},
+		func(next Handle) Handle {
+			return func(c *Context) error {
+				order = append(order, "inner")
+				if c.Request().Operation() == "blocked" {
+					c.WithCode(codes.CodeUnauthorized).WithMessage("blocked")
+					return nil
+				}
+				return next(c)
+			}
+		},
+	)
+
+	post := func(method string) *Response {
+		var resp Response
+		serveTest(t, tr, "/api", Request{Method: method, Data: Data(`{}`), Timestamp: 1, Version: "1.0", Sign: "-", SignType: SignTypeMD5}, nil, &resp)
+		return &resp
+	}
+
+	if resp := post("account.user.home"); resp.Content != "account.user.home" {
+		t.Fatal("unexpected response", resp)
+	}
+	if strings.Join(order, ",") != "outer:account.user.home,inner,outer:account.user.home" {
+		t.Fatal("unexpected middleware order", order)
+	}
+	if resp := post("account.user.blocked"); resp.Code != codes.CodeUnauthorized.Int() || resp.Content != nil {
+		t.Fatal("middleware did not short-circuit", resp)
+	}
+}
@@ -0,0 +1,168 @@
+package transport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/36625090/involution/logical"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	MIMEMultipart = "multipart/form-data"
+	//UploadEnvelopeField multipart请求中请求信封(JSON)所在的字段，其他带文件名的字段为上传文件
+	UploadEnvelopeField = "envelope"
+
+	defaultUploadMaxSize = 32 << 20
+)
+
+var errUploadTooLarge = errors.New("upload exceeds maximum size")
+
+//uploadFile 暂存到临时文件的上传文件
+type uploadFile struct {
+	*logical.File
+	path string
+}
+
+func isMultipart(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == MIMEMultipart
+}
+
+//readMultipart 读取multipart请求，请求信封写入ctx.body
+//文件边读取边计算SHA256并暂存到临时文件，总大小受UploadMaxSize限制，每个字段只允许一个文件
+func (m *Transport) readMultipart(ctx *Context) error {
+	maxSize := m.settings.UploadMaxSize
+	if maxSize <= 0 {
+		maxSize = defaultUploadMaxSize
+	}
+
+	_, params, err := mime.ParseMediaType(ctx.ctx.GetHeader("Content-Type"))
+	if err != nil {
+		return err
+	}
+	//信封和文件之外额外保留1MB给分隔符、头部和被忽略的字段
+	body := http.MaxBytesReader(ctx.ctx.Writer, ctx.ctx.Request.Body, maxSize+1<<20)
+	reader := multipart.NewReader(body, params["boundary"])
+	remaining := maxSize
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		field := part.FormName()
+		if part.FileName() == "" {
+			if field != UploadEnvelopeField {
+				part.Close()
+				continue
+			}
+			envelope, err := io.ReadAll(io.LimitReader(part, remaining+1))
+			part.Close()
+			if err != nil {
+				return err
+			}
+			if remaining -= int64(len(envelope)); remaining < 0 {
+				return errUploadTooLarge
+			}
+			ctx.body = envelope
+			continue
+		}
+
+		for _, file := range ctx.files {
+			if file.Field == field {
+				return fmt.Errorf("duplicate upload field: %s", field)
+			}
+		}
+		file, err := spoolUpload(part, remaining)
+		part.Close()
+		if err != nil {
+			return err
+		}
+		ctx.files = append(ctx.files, file)
+		if remaining -= file.Size; remaining < 0 {
+			return errUploadTooLarge
+		}
+	}
+	if ctx.body == nil {
+		return errors.New("multipart field required: " + UploadEnvelopeField)
+	}
+	return nil
+}
+
+func spoolUpload(part *multipart.Part, limit int64) (*uploadFile, error) {
+	tmp, err := os.CreateTemp("", "involution-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(part, limit+1))
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	if size > limit {
+		os.Remove(tmp.Name())
+		return nil, errUploadTooLarge
+	}
+
+	path := tmp.Name()
+	contentType := part.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return &uploadFile{
+		File: logical.NewFile(part.FormName(), part.FileName(), contentType, size, hex.EncodeToString(hash.Sum(nil)),
+			func() (io.ReadCloser, error) { return os.Open(path) }),
+		path: path,
+	}, nil
+}
+
+//verifyUploads 校验上传文件与请求信封files中签名的摘要一致
+func verifyUploads(req *Request, files []*uploadFile) error {
+	if len(req.Files) != len(files) {
+		return fmt.Errorf("upload files mismatch: signed %d, received %d", len(req.Files), len(files))
+	}
+	for _, file := range files {
+		hash, ok := req.Files[file.Field]
+		if !ok {
+			return errors.New("upload file not signed: " + file.Field)
+		}
+		if !strings.EqualFold(hash, file.Hash) {
+			return errors.New("upload file hash mismatch: " + file.Field)
+		}
+	}
+	return nil
+}
+
+//canonicalFiles 上传文件摘要的签名规范化形式: 按字段名排序的 field=sha256hex，以&连接
+func canonicalFiles(files map[string]string) string {
+	fields := make([]string, 0, len(files))
+	for field := range files {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	pairs := make([]string, 0, len(fields))
+	for _, field := range fields {
+		pairs = append(pairs, field+"="+strings.ToLower(files[field]))
+	}
+	return strings.Join(pairs, "&")
+}
+
+func removeUploads(files []*uploadFile) {
+	for _, file := range files {
+		os.Remove(file.path)
+	}
+}
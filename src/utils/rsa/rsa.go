@@ -1,8 +1,10 @@
 package rsa
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
@@ -51,4 +53,15 @@ func EncodePublicKey(key *rsa.PublicKey)string{
 		},
 	)
 	return string(privatePem)
-}
\ No newline at end of file
+}
+//SignPKCS1v15 使用SHA256摘要签名
+func SignPKCS1v15(pri *rsa.PrivateKey, data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, pri, crypto.SHA256, digest[:])
+}
+
+//VerifyPKCS1v15 校验SHA256摘要签名
+func VerifyPKCS1v15(pub *rsa.PublicKey, data []byte, sig []byte) error {
+	digest := sha256.Sum256(data)
+	return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig)
+}